@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"../board"
+	"../book"
+)
+
+// BestMoveで読む深さ
+const playDepth = 3
+
+// 残り時間がこれより少なければ深く読まない
+const hurryTime = 5 * time.Second
+
+var (
+	bookMu sync.Mutex
+	openBk *book.Book
+	rnd    = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// 定跡を使うようにする(nilで使わない)
+func SetBook(bk *book.Book) {
+	bookMu.Lock()
+	openBk = bk
+	bookMu.Unlock()
+}
+
+// 定跡にあればそこから選び、なければ読んで決める
+func BestMove(b *board.Board, which int) board.Point {
+	if p, ok := BookMove(b); ok {
+		return p
+	}
+	if p, ok := fivePoint(board.Copy(b), which); ok {
+		return p
+	}
+	p, _ := Search(b, which, playDepth)
+	return p
+}
+
+func BookMove(b *board.Board) (board.Point, bool) {
+	bookMu.Lock()
+	defer bookMu.Unlock()
+	if openBk == nil {
+		return board.Point{}, false
+	}
+	return book.Choose(openBk, b, rnd)
+}
+
+// 局面の定跡手の一覧
+func BookCandidates(b *board.Board) []book.Candidate {
+	bookMu.Lock()
+	defer bookMu.Unlock()
+	return book.Lookup(openBk, b)
+}
+
+// 対局で手を決めるもの
+// leftはwhichの残り時間で、持ち時間がなければ0
+type Engine interface {
+	Move(b *board.Board, which int, left time.Duration) board.Point
+}
+
+// このパッケージの読みで打つエンジン
+// Depthが0ならBestMoveで打つ(定跡を使う)
+type Searcher struct {
+	Depth int
+}
+
+func (s Searcher) Move(b *board.Board, which int, left time.Duration) board.Point {
+	if left > 0 && left < hurryTime {
+		if p, ok := fivePoint(board.Copy(b), which); ok {
+			return p
+		}
+		p, _ := Search(b, which, 1)
+		return p
+	}
+	if s.Depth <= 0 {
+		return BestMove(b, which)
+	}
+	p, _ := Search(b, which, s.Depth)
+	return p
+}
@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"math"
+
+	"../board"
+)
+
+// 五マスの窓に並んだ石の数ごとの点数
+var lineScore = [6]int{0, 1, 12, 150, 2000, 100000}
+
+// 勝ちが確定した局面の点数
+const WinScore = 1 << 20
+
+// which側から見た局面の点数
+func Evaluate(b *board.Board, which int) int {
+	size := board.Size(b)
+	score := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			for _, d := range dirs {
+				if board.At(b, x+d.X*4, y+d.Y*4) < 0 {
+					continue
+				}
+				own, opp := 0, 0
+				for i := 0; i < 5; i++ {
+					switch board.At(b, x+d.X*i, y+d.Y*i) {
+					case which:
+						own++
+					case board.Opponent(which):
+						opp++
+					}
+				}
+				if opp == 0 {
+					score += lineScore[own]
+				} else if own == 0 {
+					score -= lineScore[opp]
+				}
+			}
+		}
+	}
+	return score
+}
+
+// 黒の勝率
+// toMoveは次に打つ側
+func WinProbability(b *board.Board, toMove int) float64 {
+	_, score := Search(b, toMove, 2)
+	if toMove == board.White {
+		score = -score
+	}
+	return 1 / (1 + math.Exp(-float64(score)/800))
+}
+
+var dirs = [4]board.Point{{X: 1, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: -1}}
+
+// pに打ったときに関係する窓の点数(候補手の並び替え用)
+func pointScore(b *board.Board, p board.Point, which int) int {
+	score := 0
+	for _, d := range dirs {
+		for s := -4; s <= 0; s++ {
+			x0, y0 := p.X+d.X*s, p.Y+d.Y*s
+			if board.At(b, x0, y0) < 0 || board.At(b, x0+d.X*4, y0+d.Y*4) < 0 {
+				continue
+			}
+			own, opp := 0, 0
+			for i := 0; i < 5; i++ {
+				switch board.At(b, x0+d.X*i, y0+d.Y*i) {
+				case which:
+					own++
+				case board.Opponent(which):
+					opp++
+				}
+			}
+			// 自分の攻めと相手の受けの両方を数える
+			if opp == 0 {
+				score += lineScore[own+1]
+			} else if own == 0 {
+				score += lineScore[opp+1]
+			}
+		}
+	}
+	return score
+}
@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"../board"
+)
+
+const (
+	reviewDepth   = 2
+	vcfDepth      = 8
+	blunderMargin = 1500
+)
+
+type Annotation struct {
+	Move      board.Move
+	Best      board.Point // エンジンの推奨手
+	Loss      int         // 推奨手との点数差
+	Blunder   bool
+	MissedWin bool
+	WinLine   []board.Point // 見逃した四追いの手順
+}
+
+// startの局面から打たれた手順を一手ずつ検討する
+func Review(start *board.Board, moves []board.Move) ([]Annotation, error) {
+	b := board.Copy(start)
+	notes := make([]Annotation, 0, len(moves))
+	for i, m := range moves {
+		if board.At(b, m.X, m.Y) != board.Space {
+			return notes, fmt.Errorf("ai: move %d at %s is not playable", i+1, board.FormatPoint(board.Point{X: m.X, Y: m.Y}))
+		}
+		notes = append(notes, reviewMove(b, m))
+		board.Set(b, m.X, m.Y, m.Which)
+	}
+	return notes, nil
+}
+
+func reviewMove(b *board.Board, m board.Move) Annotation {
+	p := board.Point{X: m.X, Y: m.Y}
+	opp := board.Opponent(m.Which)
+	best, _ := Search(b, m.Which, reviewDepth)
+	if best.X < 0 {
+		best = p
+	}
+	a := Annotation{Move: m, Best: best}
+
+	after := board.Copy(b)
+	board.Set(after, m.X, m.Y, m.Which)
+	if board.FiveAt(after, m.X, m.Y) {
+		a.Best = p
+		return a
+	}
+	// 推奨手と打った手を同じ深さで読み、打った側から見た点数で比べる
+	a.Loss = scoreAfter(b, best, m.Which) - scoreAfter(b, p, m.Which)
+	if a.Loss < 0 {
+		a.Loss = 0
+	}
+
+	// 四追いがあったのに別の手を打った
+	if line, ok := VCF(b, m.Which, vcfDepth); ok {
+		if _, still := VCFFrom(b, m.Which, p, vcfDepth); !still {
+			a.MissedWin = true
+			a.WinLine = line
+			a.Best = line[0]
+			return a
+		}
+		a.Best = p
+		return a
+	}
+
+	// 相手に四追いを許した、または大きく損をした
+	if _, ok := VCF(after, opp, vcfDepth); ok && best != p {
+		alt := board.Copy(b)
+		board.Set(alt, best.X, best.Y, m.Which)
+		if _, altOk := VCF(alt, opp, vcfDepth); !altOk {
+			a.Blunder = true
+		}
+	}
+	if a.Loss > blunderMargin && best != p {
+		a.Blunder = true
+	}
+	return a
+}
+
+// whichがpに打った後の局面を、相手の手番からreviewDepth-1手読んだ点数
+// (whichから見た点数)
+func scoreAfter(b *board.Board, p board.Point, which int) int {
+	after := board.Copy(b)
+	board.Set(after, p.X, p.Y, which)
+	if board.FiveAt(after, p.X, p.Y) {
+		return WinScore
+	}
+	_, score := Search(after, board.Opponent(which), reviewDepth-1)
+	return -score
+}
+
+func (a Annotation) String() string {
+	var s []string
+	color := "B"
+	if a.Move.Which == board.White {
+		color = "W"
+	}
+	s = append(s, color+" "+board.FormatPoint(board.Point{X: a.Move.X, Y: a.Move.Y}))
+	if a.MissedWin {
+		s = append(s, "missed win: "+formatLine(a.WinLine))
+	} else if a.Blunder && a.Loss >= WinScore/2 {
+		s = append(s, "blunder (loses), best "+board.FormatPoint(a.Best))
+	} else if a.Blunder {
+		s = append(s, fmt.Sprintf("blunder (-%d), best %s", a.Loss, board.FormatPoint(a.Best)))
+	} else if a.Best != (board.Point{X: a.Move.X, Y: a.Move.Y}) {
+		s = append(s, "engine prefers "+board.FormatPoint(a.Best))
+	}
+	return strings.Join(s, "  ")
+}
+
+func formatLine(line []board.Point) string {
+	s := make([]string, len(line))
+	for i, p := range line {
+		s[i] = board.FormatPoint(p)
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"sort"
+
+	"../board"
+)
+
+// 一手ごとに読む候補手の数
+const maxCandidates = 12
+
+// which側の最善手と、そのときの点数を返す
+func Search(b *board.Board, which int, depth int) (board.Point, int) {
+	b = board.Copy(b)
+	best := board.Point{X: -1, Y: -1}
+	alpha := -WinScore - 1
+	for _, p := range Candidates(b, which) {
+		board.Set(b, p.X, p.Y, which)
+		var score int
+		if board.FiveAt(b, p.X, p.Y) {
+			score = WinScore
+		} else {
+			score = -negamax(b, board.Opponent(which), depth-1, -WinScore-1, -alpha)
+		}
+		board.Set(b, p.X, p.Y, board.Space)
+		if score > alpha || best.X < 0 {
+			alpha = score
+			best = p
+		}
+		if score >= WinScore {
+			break
+		}
+	}
+	if best.X < 0 {
+		return best, Evaluate(b, which)
+	}
+	return best, alpha
+}
+
+func negamax(b *board.Board, which int, depth int, alpha int, beta int) int {
+	if depth <= 0 {
+		return Evaluate(b, which)
+	}
+	cands := Candidates(b, which)
+	if len(cands) == 0 {
+		return 0
+	}
+	for _, p := range cands {
+		board.Set(b, p.X, p.Y, which)
+		var score int
+		if board.FiveAt(b, p.X, p.Y) {
+			score = WinScore
+		} else {
+			score = -negamax(b, board.Opponent(which), depth-1, -beta, -alpha)
+		}
+		board.Set(b, p.X, p.Y, board.Space)
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return alpha
+}
+
+// 石の近く(2マス以内)の空点を有望な順に返す
+func Candidates(b *board.Board, which int) []board.Point {
+	size := board.Size(b)
+	var cands []board.Point
+	empty := true
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if board.At(b, x, y) != board.Space {
+				empty = false
+				continue
+			}
+			if nearStone(b, x, y) {
+				cands = append(cands, board.Point{X: x, Y: y})
+			}
+		}
+	}
+	if empty {
+		return []board.Point{{X: size / 2, Y: size / 2}}
+	}
+
+	scores := make(map[board.Point]int, len(cands))
+	for _, p := range cands {
+		scores[p] = pointScore(b, p, which)
+	}
+	sort.SliceStable(cands, func(i, j int) bool {
+		return scores[cands[i]] > scores[cands[j]]
+	})
+	if len(cands) > maxCandidates {
+		cands = cands[:maxCandidates]
+	}
+	return cands
+}
+
+func nearStone(b *board.Board, x int, y int) bool {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			if board.At(b, x+dx, y+dy) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package ai
+
+import (
+	"../board"
+)
+
+// VCF(四追い)を探す
+// 見つかれば攻め方と受け方の手を交互に並べた手順を返す
+func VCF(b *board.Board, which int, depth int) ([]board.Point, bool) {
+	return vcf(board.Copy(b), which, depth)
+}
+
+// pから始まる四追いがあるか
+func VCFFrom(b *board.Board, which int, p board.Point, depth int) ([]board.Point, bool) {
+	b = board.Copy(b)
+	if board.At(b, p.X, p.Y) != board.Space {
+		return nil, false
+	}
+	if _, ok := fivePoint(b, board.Opponent(which)); ok {
+		return nil, false
+	}
+	return tryFour(b, which, p, depth)
+}
+
+func vcf(b *board.Board, which int, depth int) ([]board.Point, bool) {
+	if p, ok := fivePoint(b, which); ok {
+		return []board.Point{p}, true
+	}
+	// 相手に五があれば四を打っても先に五を作られる
+	if _, ok := fivePoint(b, board.Opponent(which)); ok {
+		return nil, false
+	}
+	if depth <= 0 {
+		return nil, false
+	}
+	size := board.Size(b)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if board.At(b, x, y) != board.Space {
+				continue
+			}
+			if line, ok := tryFour(b, which, board.Point{X: x, Y: y}, depth); ok {
+				return line, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func tryFour(b *board.Board, which int, p board.Point, depth int) ([]board.Point, bool) {
+	board.Set(b, p.X, p.Y, which)
+	defer board.Set(b, p.X, p.Y, board.Space)
+
+	if board.FiveAt(b, p.X, p.Y) {
+		return []board.Point{p}, true
+	}
+	gaps := fourGaps(b, p, which)
+	switch {
+	case len(gaps) == 0:
+		return nil, false
+	case len(gaps) >= 2:
+		// 止めようがない四(活四・四々)
+		return []board.Point{p, gaps[0], gaps[1]}, true
+	}
+
+	g := gaps[0]
+	opp := board.Opponent(which)
+	board.Set(b, g.X, g.Y, opp)
+	defer board.Set(b, g.X, g.Y, board.Space)
+	if board.FiveAt(b, g.X, g.Y) {
+		return nil, false
+	}
+	rest, ok := vcf(b, which, depth-1)
+	if !ok {
+		return nil, false
+	}
+	return append([]board.Point{p, g}, rest...), true
+}
+
+// pの石を含む四の、五になる空点
+func fourGaps(b *board.Board, p board.Point, which int) []board.Point {
+	var gaps []board.Point
+	seen := map[board.Point]bool{}
+	for _, d := range dirs {
+		for s := -4; s <= 0; s++ {
+			x0, y0 := p.X+d.X*s, p.Y+d.Y*s
+			if board.At(b, x0, y0) < 0 || board.At(b, x0+d.X*4, y0+d.Y*4) < 0 {
+				continue
+			}
+			own := 0
+			gap := board.Point{X: -1, Y: -1}
+			for i := 0; i < 5; i++ {
+				switch board.At(b, x0+d.X*i, y0+d.Y*i) {
+				case which:
+					own++
+				case board.Space:
+					gap = board.Point{X: x0 + d.X*i, Y: y0 + d.Y*i}
+				}
+			}
+			if own == 4 && gap.X >= 0 && !seen[gap] {
+				seen[gap] = true
+				gaps = append(gaps, gap)
+			}
+		}
+	}
+	return gaps
+}
+
+// 次の一手で五になる空点
+func fivePoint(b *board.Board, which int) (board.Point, bool) {
+	size := board.Size(b)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if board.At(b, x, y) != board.Space {
+				continue
+			}
+			board.Set(b, x, y, which)
+			five := board.FiveAt(b, x, y)
+			board.Set(b, x, y, board.Space)
+			if five {
+				return board.Point{X: x, Y: y}, true
+			}
+		}
+	}
+	return board.Point{}, false
+}
@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"../board"
+)
+
+// VCT(三と四による追い詰め)を探す
+// 受け方の逆四は反撃とみなして読まない
+func VCT(b *board.Board, which int, depth int) ([]board.Point, bool) {
+	return vct(board.Copy(b), which, depth)
+}
+
+func vct(b *board.Board, which int, depth int) ([]board.Point, bool) {
+	if line, ok := vcf(b, which, vcfDepth); ok {
+		return line, true
+	}
+	opp := board.Opponent(which)
+	if _, ok := fivePoint(b, opp); ok || depth <= 0 {
+		return nil, false
+	}
+	size := board.Size(b)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			p := board.Point{X: x, Y: y}
+			if board.At(b, x, y) != board.Space || !nearStone(b, x, y) {
+				continue
+			}
+			board.Set(b, x, y, which)
+			defenses := threeDefenses(b, p, which)
+			var line []board.Point
+			won := len(defenses) > 0
+			for _, d := range defenses {
+				board.Set(b, d.X, d.Y, opp)
+				rest, ok := vct(b, which, depth-1)
+				board.Set(b, d.X, d.Y, board.Space)
+				if !ok {
+					won = false
+					break
+				}
+				if line == nil {
+					line = append([]board.Point{p, d}, rest...)
+				}
+			}
+			board.Set(b, x, y, board.Space)
+			if won {
+				return line, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// pの石で活三ができていれば、受け方の候補(三を止める点と逆四の点)を返す
+func threeDefenses(b *board.Board, p board.Point, which int) []board.Point {
+	seen := map[board.Point]bool{}
+	var defenses []board.Point
+	add := func(q board.Point) {
+		if !seen[q] {
+			seen[q] = true
+			defenses = append(defenses, q)
+		}
+	}
+	for _, d := range dirs {
+		for s := -3; s <= 0; s++ {
+			x0, y0 := p.X+d.X*(s-1), p.Y+d.Y*(s-1)
+			if board.At(b, x0, y0) != board.Space || board.At(b, x0+d.X*5, y0+d.Y*5) != board.Space {
+				continue
+			}
+			own := 0
+			var gaps []board.Point
+			for i := 1; i <= 4; i++ {
+				switch board.At(b, x0+d.X*i, y0+d.Y*i) {
+				case which:
+					own++
+				case board.Space:
+					gaps = append(gaps, board.Point{X: x0 + d.X*i, Y: y0 + d.Y*i})
+				}
+			}
+			if own != 3 || len(gaps) != 1 {
+				continue
+			}
+			add(board.Point{X: x0, Y: y0})
+			add(gaps[0])
+			add(board.Point{X: x0 + d.X*5, Y: y0 + d.Y*5})
+		}
+	}
+	if len(defenses) == 0 {
+		return nil
+	}
+
+	// 受け方が四を打てるならそれも受けの候補
+	opp := board.Opponent(which)
+	size := board.Size(b)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if board.At(b, x, y) != board.Space {
+				continue
+			}
+			q := board.Point{X: x, Y: y}
+			board.Set(b, x, y, opp)
+			if len(fourGaps(b, q, opp)) > 0 {
+				add(q)
+			}
+			board.Set(b, x, y, board.Space)
+		}
+	}
+	return defenses
+}
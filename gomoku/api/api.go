@@ -0,0 +1,402 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"../archive"
+	"../board"
+	"../game"
+	"../record"
+)
+
+// 画面のないWebのダッシュボードやボットから対局するためのHTTPのAPI
+//
+//	GET  /games                 対局の一覧(?status=active|over)
+//	POST /games                 対局を作る
+//	GET  /games/{id}            対局の状態
+//	POST /games/{id}/moves      手番の側が一手打つ
+//	POST /games/{id}/actions    投了、引き分けの提案など
+//	GET  /games/{id}/sgf        棋譜(SGF)
+//	GET  /openapi.json          この一覧の詳しい定義
+//
+// 本文と返事はJSONで、エラーは{"error": "..."}で返す
+// 対局を作ると黒と白の席の合言葉が返り、打つときと対局を操作するときは
+// Authorization: Bearer <合言葉> でどちらの席かを示す
+const DefaultSize = 15
+
+var (
+	ErrNoGame      = errors.New("api: no such game")
+	ErrBadToken    = errors.New("api: missing or bad seat token")
+	ErrBadAction   = errors.New("api: unknown action")
+	ErrNotYourTurn = errors.New("api: not your turn")
+	errBodyTooBig  = errors.New("api: request body is too large")
+)
+
+// 本文の大きさの上限
+const maxBody = 1 << 16
+
+type entry struct {
+	id      string
+	game    *game.Game
+	names   [2]string
+	tokens  [2]string // 黒、白の席の合言葉
+	created time.Time
+}
+
+// Authorizationの合言葉の席(合わなければSpace)
+func (e *entry) seat(r *http.Request) int {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	for i, t := range e.tokens {
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return i + 1
+		}
+	}
+	return board.Space
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+type Server struct {
+	// 設定されていれば、終局した対局を保存する
+	Archive *archive.Archive
+
+	mu     sync.Mutex
+	games  map[string]*entry
+	nextID int
+}
+
+func NewServer() *Server {
+	return &Server{games: map[string]*entry{}}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "openapi.json":
+		s.only(w, r, "GET", serveSpec)
+	case path == "games":
+		switch r.Method {
+		case "GET":
+			s.list(w, r)
+		case "POST":
+			s.create(w, r)
+		default:
+			methodNotAllowed(w, "GET, POST")
+		}
+	case len(parts) == 2 && parts[0] == "games":
+		s.only(w, r, "GET", func(w http.ResponseWriter, r *http.Request) { s.get(w, parts[1]) })
+	case len(parts) == 3 && parts[0] == "games" && parts[2] == "moves":
+		s.only(w, r, "POST", func(w http.ResponseWriter, r *http.Request) { s.move(w, r, parts[1]) })
+	case len(parts) == 3 && parts[0] == "games" && parts[2] == "actions":
+		s.only(w, r, "POST", func(w http.ResponseWriter, r *http.Request) { s.action(w, r, parts[1]) })
+	case len(parts) == 3 && parts[0] == "games" && parts[2] == "sgf":
+		s.only(w, r, "GET", func(w http.ResponseWriter, r *http.Request) { s.sgf(w, parts[1]) })
+	default:
+		writeError(w, http.StatusNotFound, errors.New("api: not found"))
+	}
+}
+
+func (s *Server) only(w http.ResponseWriter, r *http.Request, method string, h http.HandlerFunc) {
+	if r.Method != method {
+		methodNotAllowed(w, method)
+		return
+	}
+	h(w, r)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	s.mu.Lock()
+	var es []*entry
+	for _, e := range s.games {
+		if e.game.CheckTime() {
+			s.finished(e)
+		}
+		switch {
+		case status == "active" && e.game.Over(), status == "over" && !e.game.Over():
+			continue
+		}
+		es = append(es, e)
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].created.After(es[j].created) })
+	games := []GameSummary{}
+	for _, e := range es {
+		games = append(games, summaryOf(e))
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, games)
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	var req NewGame
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	g, err := newGame(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.mu.Lock()
+	s.nextID++
+	e := &entry{
+		id:      strconv.Itoa(s.nextID),
+		game:    g,
+		names:   [2]string{req.Black, req.White},
+		tokens:  [2]string{newToken(), newToken()},
+		created: time.Now(),
+	}
+	s.games[e.id] = e
+	g.StartClock()
+	created := Created{GameState: stateOf(e), Tokens: Tokens{Black: e.tokens[0], White: e.tokens[1]}}
+	s.mu.Unlock()
+	w.Header().Set("Location", "/games/"+e.id)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func newGame(req NewGame) (*game.Game, error) {
+	size := req.Size
+	if size == 0 {
+		size = DefaultSize
+	}
+	if size < 5 || size > 26 {
+		return nil, errors.New("api: size must be between 5 and 26")
+	}
+	rule := board.Freestyle
+	if req.Rule != "" {
+		var err error
+		if rule, err = board.ParseRule(req.Rule); err != nil {
+			return nil, err
+		}
+	}
+	g := game.New(size)
+	if req.Start != "" {
+		start, toMove, r, err := board.ParsePosition(req.Start)
+		if err != nil {
+			return nil, err
+		}
+		if board.Size(start) != size && req.Size != 0 {
+			return nil, errors.New("api: start position does not match size")
+		}
+		g = game.FromPosition(start, toMove)
+		// ルールを書いていなければ局面の表記のルールを使う
+		if req.Rule == "" {
+			rule = r
+		}
+	}
+	g.Rule = rule
+	if req.Time != "" {
+		tc, err := game.ParseTimeControl(req.Time)
+		if err != nil {
+			return nil, err
+		}
+		g.Clock = game.NewClock(tc)
+	}
+	return g, nil
+}
+
+func (s *Server) get(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	e := s.games[id]
+	if e == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, ErrNoGame)
+		return
+	}
+	if e.game.CheckTime() {
+		s.finished(e)
+	}
+	st := stateOf(e)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) move(w http.ResponseWriter, r *http.Request, id string) {
+	var req Move
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	p, err := board.ParsePoint(req.Move)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.games[id]
+	if e == nil {
+		writeError(w, http.StatusNotFound, ErrNoGame)
+		return
+	}
+	g := e.game
+	which := e.seat(r)
+	if which == board.Space {
+		writeError(w, http.StatusUnauthorized, ErrBadToken)
+		return
+	}
+	if !g.Over() && which != g.Turn {
+		writeError(w, http.StatusConflict, ErrNotYourTurn)
+		return
+	}
+	if p.X >= g.Size || p.Y >= g.Size {
+		writeError(w, http.StatusBadRequest, game.ErrNotPlayable)
+		return
+	}
+	if err := g.Play(p.X, p.Y); err != nil {
+		// 時間切れなら終局として記録してから知らせる
+		if g.Over() && err != game.ErrGameOver {
+			s.finished(e)
+		}
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if g.Over() {
+		s.finished(e)
+	}
+	writeJSON(w, http.StatusOK, stateOf(e))
+}
+
+func (s *Server) action(w http.ResponseWriter, r *http.Request, id string) {
+	var req Action
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.games[id]
+	if e == nil {
+		writeError(w, http.StatusNotFound, ErrNoGame)
+		return
+	}
+	which := e.seat(r)
+	if which == board.Space {
+		writeError(w, http.StatusUnauthorized, ErrBadToken)
+		return
+	}
+	g := e.game
+	var err error
+	switch req.Action {
+	case "resign":
+		err = g.Resign(which)
+	case "draw":
+		err = g.OfferDraw(which)
+	case "swap":
+		err = g.OfferSwap(which)
+	case "accept":
+		var o game.Offer
+		o, err = g.Accept(which)
+		// 入れ替えたら合言葉も対局者について行く
+		if err == nil && o.Kind == game.SwapOffer {
+			e.names[0], e.names[1] = e.names[1], e.names[0]
+			e.tokens[0], e.tokens[1] = e.tokens[1], e.tokens[0]
+		}
+	case "decline":
+		_, err = g.Decline(which)
+	case "claim":
+		err = g.ClaimDraw(which)
+	case "abort":
+		err = g.Abort(which)
+	default:
+		writeError(w, http.StatusBadRequest, ErrBadAction)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if g.Over() {
+		s.finished(e)
+	}
+	writeJSON(w, http.StatusOK, stateOf(e))
+}
+
+func (s *Server) sgf(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	e := s.games[id]
+	var rec *record.Game
+	if e != nil {
+		rec = recordOf(e)
+	}
+	s.mu.Unlock()
+	if rec == nil {
+		writeError(w, http.StatusNotFound, ErrNoGame)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-go-sgf")
+	if err := record.WriteSGF(w, rec); err != nil {
+		log.Println(err)
+	}
+}
+
+func recordOf(e *entry) *record.Game {
+	rec := e.game.Record()
+	rec.Black, rec.White = e.names[0], e.names[1]
+	rec.Date = e.created.Format("2006-01-02")
+	return rec
+}
+
+// 終局した対局をアーカイブに入れる
+func (s *Server) finished(e *entry) {
+	if s.Archive == nil {
+		return
+	}
+	if _, err := s.Archive.Add(recordOf(e), time.Now()); err != nil {
+		log.Println(err)
+	}
+}
+
+func readJSON(r *http.Request, v interface{}) error {
+	body := http.MaxBytesReader(nil, r.Body, maxBody)
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return errBodyTooBig
+		}
+		if err == io.EOF {
+			return errors.New("api: empty request body")
+		}
+		return err
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Error{Error: err.Error()})
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, http.StatusMethodNotAllowed, errors.New("api: method not allowed"))
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"../record"
+)
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func do(t *testing.T, ts *httptest.Server, method string, path string, token string, body string) response {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response{res.StatusCode, res.Header, data}
+}
+
+func (r response) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.body, v); err != nil {
+		t.Fatalf("%v: %s", err, r.body)
+	}
+}
+
+func wantStatus(t *testing.T, r response, status int) {
+	t.Helper()
+	if r.status != status {
+		t.Fatalf("status = %d, want %d: %s", r.status, status, r.body)
+	}
+}
+
+func create(t *testing.T, ts *httptest.Server, body string) Created {
+	t.Helper()
+	r := do(t, ts, "POST", "/games", "", body)
+	wantStatus(t, r, http.StatusCreated)
+	var c Created
+	r.decode(t, &c)
+	if r.header.Get("Location") != "/games/"+c.ID {
+		t.Fatalf("Location = %q", r.header.Get("Location"))
+	}
+	return c
+}
+
+func TestCreateAndGet(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+
+	c := create(t, ts, `{"size":9,"black":"alice","white":"bob","time":"5m+3s"}`)
+	if c.Size != 9 || c.Rule != "freestyle" || c.Turn != "black" || len(c.Moves) != 0 {
+		t.Fatalf("created = %+v", c.GameState)
+	}
+	if c.Tokens.Black == "" || c.Tokens.White == "" || c.Tokens.Black == c.Tokens.White {
+		t.Fatalf("tokens = %+v", c.Tokens)
+	}
+	if c.Clock == nil || c.Time == "" {
+		t.Fatal("clock missing")
+	}
+
+	r := do(t, ts, "GET", "/games/"+c.ID, "", "")
+	wantStatus(t, r, http.StatusOK)
+	// 状態を見るだけでは合言葉は返らない
+	if strings.Contains(string(r.body), c.Tokens.Black) {
+		t.Fatal("GET leaks a seat token")
+	}
+	var st GameState
+	r.decode(t, &st)
+	if st.ID != c.ID || st.Black != "alice" || st.White != "bob" {
+		t.Fatalf("state = %+v", st)
+	}
+
+	wantStatus(t, do(t, ts, "GET", "/games/99", "", ""), http.StatusNotFound)
+	wantStatus(t, do(t, ts, "DELETE", "/games/"+c.ID, "", ""), http.StatusMethodNotAllowed)
+	wantStatus(t, do(t, ts, "POST", "/games", "", `{"size":3}`), http.StatusBadRequest)
+	wantStatus(t, do(t, ts, "POST", "/games", "", `{"rule":"go"}`), http.StatusBadRequest)
+	wantStatus(t, do(t, ts, "POST", "/games", "", `{"bogus":1}`), http.StatusBadRequest)
+}
+
+func TestMoves(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+	c := create(t, ts, `{"size":9}`)
+	path := "/games/" + c.ID + "/moves"
+
+	r := do(t, ts, "POST", path, c.Tokens.Black, `{"move":"e5"}`)
+	wantStatus(t, r, http.StatusOK)
+	var st GameState
+	r.decode(t, &st)
+	if len(st.Moves) != 1 || st.Moves[0] != "e5" || st.Turn != "white" {
+		t.Fatalf("state = %+v", st)
+	}
+
+	// 埋まっている点
+	wantStatus(t, do(t, ts, "POST", path, c.Tokens.White, `{"move":"e5"}`), http.StatusConflict)
+	// 手番でない側
+	r = do(t, ts, "POST", path, c.Tokens.Black, `{"move":"d4"}`)
+	wantStatus(t, r, http.StatusConflict)
+	var e Error
+	r.decode(t, &e)
+	if e.Error != ErrNotYourTurn.Error() {
+		t.Fatalf("error = %q", e.Error)
+	}
+	// 合言葉がない、違う
+	wantStatus(t, do(t, ts, "POST", path, "", `{"move":"d4"}`), http.StatusUnauthorized)
+	wantStatus(t, do(t, ts, "POST", path, "nope", `{"move":"d4"}`), http.StatusUnauthorized)
+	// 座標がおかしい、盤の外
+	wantStatus(t, do(t, ts, "POST", path, c.Tokens.White, `{"move":"??"}`), http.StatusBadRequest)
+	wantStatus(t, do(t, ts, "POST", path, c.Tokens.White, `{"move":"z9"}`), http.StatusBadRequest)
+
+	// 五で終局したら打てない
+	moves := []string{"a1", "e6", "a2", "e7", "a3", "e8", "a4", "e9"}
+	for i, m := range moves {
+		token := c.Tokens.White
+		if i%2 == 1 {
+			token = c.Tokens.Black
+		}
+		r = do(t, ts, "POST", path, token, `{"move":"`+m+`"}`)
+		wantStatus(t, r, http.StatusOK)
+	}
+	var over GameState
+	r.decode(t, &over)
+	if over.Result != record.BlackWin || over.Turn != "" || over.Ending == "" {
+		t.Fatalf("state = %+v", over)
+	}
+	wantStatus(t, do(t, ts, "POST", path, c.Tokens.White, `{"move":"b1"}`), http.StatusConflict)
+}
+
+func TestList(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+	a := create(t, ts, `{"size":9}`)
+	b := create(t, ts, `{"size":13}`)
+	wantStatus(t, do(t, ts, "POST", "/games/"+a.ID+"/actions", a.Tokens.White, `{"action":"resign"}`), http.StatusOK)
+
+	list := func(query string) []GameSummary {
+		t.Helper()
+		r := do(t, ts, "GET", "/games"+query, "", "")
+		wantStatus(t, r, http.StatusOK)
+		var games []GameSummary
+		r.decode(t, &games)
+		return games
+	}
+	if all := list(""); len(all) != 2 || all[0].ID != b.ID {
+		t.Fatalf("all = %+v", all)
+	}
+	if active := list("?status=active"); len(active) != 1 || active[0].ID != b.ID {
+		t.Fatalf("active = %+v", active)
+	}
+	if over := list("?status=over"); len(over) != 1 || over[0].ID != a.ID || over[0].Result != record.BlackWin+"R" {
+		t.Fatalf("over = %+v", over)
+	}
+}
+
+func TestActions(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+	c := create(t, ts, `{"size":9,"black":"alice","white":"bob"}`)
+	path := "/games/" + c.ID + "/actions"
+	action := func(token string, name string, status int) GameState {
+		t.Helper()
+		r := do(t, ts, "POST", path, token, `{"action":"`+name+`"}`)
+		wantStatus(t, r, status)
+		var st GameState
+		if status == http.StatusOK {
+			r.decode(t, &st)
+		}
+		return st
+	}
+
+	action("", "resign", http.StatusUnauthorized)
+	action(c.Tokens.Black, "dance", http.StatusBadRequest)
+	// 提案がなければ受けられない
+	action(c.Tokens.White, "accept", http.StatusConflict)
+
+	// 入れ替えると名前と合言葉が対局者について行く
+	st := action(c.Tokens.Black, "swap", http.StatusOK)
+	if st.Offer == nil || st.Offer.Kind != "swap" || st.Offer.By != "black" {
+		t.Fatalf("offer = %+v", st.Offer)
+	}
+	st = action(c.Tokens.White, "accept", http.StatusOK)
+	if st.Black != "bob" || st.White != "alice" || st.Offer != nil {
+		t.Fatalf("after swap = %+v", st)
+	}
+	// aliceの合言葉は今は白の席
+	r := do(t, ts, "POST", "/games/"+c.ID+"/moves", c.Tokens.Black, `{"move":"e5"}`)
+	wantStatus(t, r, http.StatusConflict)
+	wantStatus(t, do(t, ts, "POST", "/games/"+c.ID+"/moves", c.Tokens.White, `{"move":"e5"}`), http.StatusOK)
+
+	st = action(c.Tokens.Black, "draw", http.StatusOK)
+	if st.Offer == nil || st.Offer.By != "white" {
+		t.Fatalf("offer = %+v", st.Offer)
+	}
+	st = action(c.Tokens.White, "decline", http.StatusOK)
+	if st.Offer != nil {
+		t.Fatal("offer not cleared")
+	}
+	// aliceが投了すると白の負け
+	st = action(c.Tokens.Black, "resign", http.StatusOK)
+	if st.Result != record.BlackWin+"R" || st.Ending != "resignation" {
+		t.Fatalf("result = %+v", st)
+	}
+	action(c.Tokens.White, "resign", http.StatusConflict)
+}
+
+func TestSGF(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+	c := create(t, ts, `{"size":9,"black":"alice","white":"bob"}`)
+	do(t, ts, "POST", "/games/"+c.ID+"/moves", c.Tokens.Black, `{"move":"e5"}`)
+	do(t, ts, "POST", "/games/"+c.ID+"/moves", c.Tokens.White, `{"move":"d4"}`)
+
+	r := do(t, ts, "GET", "/games/"+c.ID+"/sgf", "", "")
+	wantStatus(t, r, http.StatusOK)
+	if ct := r.header.Get("Content-Type"); ct != "application/x-go-sgf" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	g, err := record.ReadSGF(strings.NewReader(string(r.body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Size != 9 || g.Black != "alice" || g.White != "bob" {
+		t.Fatalf("record = %+v", g)
+	}
+	if moves := record.MainLine(g); len(moves) != 2 || moves[0].X != 4 || moves[0].Y != 4 || moves[1].X != 3 {
+		t.Fatalf("moves = %+v", moves)
+	}
+	wantStatus(t, do(t, ts, "GET", "/games/99/sgf", "", ""), http.StatusNotFound)
+}
+
+func TestSpec(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+	r := do(t, ts, "GET", "/openapi.json", "", "")
+	wantStatus(t, r, http.StatusOK)
+	var spec struct {
+		OpenAPI string                 `json:"openapi"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	r.decode(t, &spec)
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("openapi = %q", spec.OpenAPI)
+	}
+	for _, p := range []string{"/games", "/games/{id}", "/games/{id}/moves", "/games/{id}/actions", "/games/{id}/sgf"} {
+		if spec.Paths[p] == nil {
+			t.Errorf("spec has no path %s", p)
+		}
+	}
+}
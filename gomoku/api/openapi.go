@@ -0,0 +1,205 @@
+package api
+
+import (
+	"io"
+	"net/http"
+)
+
+func serveSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, Spec)
+}
+
+// APIの定義(OpenAPI 3.0)
+// schema.goの型を変えたらここも直す
+const Spec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Gomoku game API",
+    "version": "1.0.0",
+    "description": "Create games, play moves and fetch records. Points are written like h8 (column letter, row number from 1). Creating a game returns a token for each seat; moves and actions must send the acting seat's token as a bearer token. Errors are returned as {\"error\": \"...\"}."
+  },
+  "paths": {
+    "/games": {
+      "get": {
+        "summary": "List games, newest first",
+        "parameters": [
+          {"name": "status", "in": "query", "schema": {"type": "string", "enum": ["active", "over"]}}
+        ],
+        "responses": {
+          "200": {"description": "Games", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/GameSummary"}}}}}
+        }
+      },
+      "post": {
+        "summary": "Create a game",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NewGame"}}}},
+        "responses": {
+          "201": {"description": "Created game with the seat tokens", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Created"}}}},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/games/{id}": {
+      "parameters": [{"$ref": "#/components/parameters/id"}],
+      "get": {
+        "summary": "Get the state of a game",
+        "responses": {
+          "200": {"description": "Game state", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GameState"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/games/{id}/moves": {
+      "parameters": [{"$ref": "#/components/parameters/id"}],
+      "post": {
+        "summary": "Play a move for the side to move",
+        "security": [{"seat": []}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Move"}}}},
+        "responses": {
+          "200": {"description": "Game state after the move", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GameState"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"description": "The point is taken, it is not that side's turn, the game is over or time ran out", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
+    "/games/{id}/actions": {
+      "parameters": [{"$ref": "#/components/parameters/id"}],
+      "post": {
+        "summary": "Resign, offer or answer a draw or colour swap, claim a draw or abort for the token's seat",
+        "security": [{"seat": []}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Action"}}}},
+        "responses": {
+          "200": {"description": "Game state after the action", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GameState"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"description": "The action is not allowed now", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
+    "/games/{id}/sgf": {
+      "parameters": [{"$ref": "#/components/parameters/id"}],
+      "get": {
+        "summary": "Get the game record as SGF",
+        "responses": {
+          "200": {"description": "SGF record", "content": {"application/x-go-sgf": {"schema": {"type": "string"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "seat": {"type": "http", "scheme": "bearer", "description": "Seat token from the response to POST /games"}
+    },
+    "parameters": {
+      "id": {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+    },
+    "responses": {
+      "Error": {"description": "Error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+    },
+    "schemas": {
+      "NewGame": {
+        "type": "object",
+        "properties": {
+          "size": {"type": "integer", "minimum": 5, "maximum": 26, "default": 15},
+          "rule": {"type": "string", "enum": ["freestyle", "standard", "renju"], "default": "freestyle"},
+          "time": {"type": "string", "description": "Time control such as 10m, 5m+3s, 10m/30s*5 or 10m/5m:25; no clock if omitted", "example": "5m+3s"},
+          "black": {"type": "string"},
+          "white": {"type": "string"},
+          "start": {"type": "string", "description": "Starting position in the position notation"}
+        }
+      },
+      "Move": {
+        "type": "object",
+        "required": ["move"],
+        "properties": {
+          "move": {"type": "string", "example": "h8"}
+        }
+      },
+      "Action": {
+        "type": "object",
+        "required": ["action"],
+        "properties": {
+          "action": {"type": "string", "enum": ["resign", "draw", "swap", "accept", "decline", "claim", "abort"]}
+        }
+      },
+      "GameState": {
+        "type": "object",
+        "required": ["id", "size", "rule", "start", "moves", "created"],
+        "properties": {
+          "id": {"type": "string"},
+          "size": {"type": "integer"},
+          "rule": {"type": "string"},
+          "black": {"type": "string"},
+          "white": {"type": "string"},
+          "start": {"type": "string", "description": "Starting position in the position notation"},
+          "moves": {"type": "array", "items": {"type": "string"}},
+          "turn": {"type": "string", "enum": ["black", "white"], "description": "Absent when the game is over"},
+          "result": {"type": "string", "description": "B+, W+, Draw or Void, with R, T or F for resignation, time or forfeit", "example": "B+R"},
+          "ending": {"type": "string", "example": "resignation"},
+          "offer": {"$ref": "#/components/schemas/Offer"},
+          "time": {"type": "string"},
+          "clock": {"$ref": "#/components/schemas/Clock"},
+          "created": {"type": "string", "format": "date-time"}
+        }
+      },
+      "Created": {
+        "allOf": [
+          {"$ref": "#/components/schemas/GameState"},
+          {
+            "type": "object",
+            "required": ["tokens"],
+            "properties": {
+              "tokens": {
+                "type": "object",
+                "description": "Seat tokens; only returned here, so hand each to its player. They follow the players if colours are swapped.",
+                "required": ["black", "white"],
+                "properties": {"black": {"type": "string"}, "white": {"type": "string"}}
+              }
+            }
+          }
+        ]
+      },
+      "GameSummary": {
+        "type": "object",
+        "required": ["id", "size", "rule", "moves"],
+        "properties": {
+          "id": {"type": "string"},
+          "size": {"type": "integer"},
+          "rule": {"type": "string"},
+          "black": {"type": "string"},
+          "white": {"type": "string"},
+          "moves": {"type": "integer"},
+          "turn": {"type": "string", "enum": ["black", "white"]},
+          "result": {"type": "string"}
+        }
+      },
+      "Offer": {
+        "type": "object",
+        "required": ["kind", "by"],
+        "properties": {
+          "kind": {"type": "string", "enum": ["draw", "swap"]},
+          "by": {"type": "string", "enum": ["black", "white"]}
+        }
+      },
+      "Clock": {
+        "type": "object",
+        "description": "Time left in milliseconds",
+        "required": ["black", "white"],
+        "properties": {
+          "black": {"type": "integer", "format": "int64"},
+          "white": {"type": "integer", "format": "int64"}
+        }
+      },
+      "Error": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {"error": {"type": "string"}}
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"time"
+
+	"../board"
+	"../game"
+)
+
+// POST /gamesの本文
+type NewGame struct {
+	Size  int    `json:"size,omitempty"` // 省略すると15
+	Rule  string `json:"rule,omitempty"` // 省略するとfreestyle
+	Time  string `json:"time,omitempty"` // game.TimeControlの表記。省略すると持ち時間なし
+	Black string `json:"black,omitempty"`
+	White string `json:"white,omitempty"`
+	// board.FormatPositionの表記の開始局面
+	Start string `json:"start,omitempty"`
+}
+
+// POST /games/{id}/movesの本文
+type Move struct {
+	Move string `json:"move"` // "h8"のような座標
+}
+
+// POST /games/{id}/actionsの本文
+type Action struct {
+	Action string `json:"action"` // resign, draw, swap, accept, decline, claim, abort
+}
+
+// 対局の状態
+type GameState struct {
+	ID      string    `json:"id"`
+	Size    int       `json:"size"`
+	Rule    string    `json:"rule"`
+	Black   string    `json:"black,omitempty"`
+	White   string    `json:"white,omitempty"`
+	Start   string    `json:"start"`
+	Moves   []string  `json:"moves"`
+	Turn    string    `json:"turn,omitempty"` // 終局していれば空
+	Result  string    `json:"result,omitempty"`
+	Ending  string    `json:"ending,omitempty"`
+	Offer   *Offer    `json:"offer,omitempty"`
+	Time    string    `json:"time,omitempty"`
+	Clock   *Clock    `json:"clock,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// POST /gamesの返事
+// 合言葉はこの返事でしか渡さないので、それぞれの対局者に伝える
+type Created struct {
+	GameState
+	Tokens Tokens `json:"tokens"`
+}
+
+type Tokens struct {
+	Black string `json:"black"`
+	White string `json:"white"`
+}
+
+// 返事を待っている提案
+type Offer struct {
+	Kind string `json:"kind"` // draw, swap
+	By   string `json:"by"`
+}
+
+// 残り時間(ミリ秒)
+type Clock struct {
+	Black int64 `json:"black"`
+	White int64 `json:"white"`
+}
+
+// GET /gamesの一覧の一行
+type GameSummary struct {
+	ID     string `json:"id"`
+	Size   int    `json:"size"`
+	Rule   string `json:"rule"`
+	Black  string `json:"black,omitempty"`
+	White  string `json:"white,omitempty"`
+	Moves  int    `json:"moves"`
+	Turn   string `json:"turn,omitempty"`
+	Result string `json:"result,omitempty"`
+}
+
+type Error struct {
+	Error string `json:"error"`
+}
+
+func colorName(which int) string {
+	switch which {
+	case board.Black:
+		return "black"
+	case board.White:
+		return "white"
+	}
+	return ""
+}
+
+var offerNames = map[game.OfferKind]string{
+	game.DrawOffer: "draw",
+	game.SwapOffer: "swap",
+}
+
+func stateOf(e *entry) GameState {
+	g := e.game
+	first := g.Turn
+	if len(g.Moves) > 0 {
+		first = g.Moves[0].Which
+	}
+	moves := make([]string, len(g.Moves))
+	for i, m := range g.Moves {
+		moves[i] = board.FormatPoint(board.Point{X: m.X, Y: m.Y})
+	}
+	st := GameState{
+		ID:      e.id,
+		Size:    g.Size,
+		Rule:    g.Rule.String(),
+		Black:   e.names[0],
+		White:   e.names[1],
+		Start:   board.FormatPosition(g.Start, first, g.Rule),
+		Moves:   moves,
+		Result:  g.Result,
+		Created: e.created,
+	}
+	if g.Over() {
+		st.Ending = g.Ending.String()
+	} else {
+		st.Turn = colorName(g.Turn)
+	}
+	if o := g.Offer; o.Kind != game.NoOffer {
+		st.Offer = &Offer{Kind: offerNames[o.Kind], By: colorName(o.By)}
+	}
+	if c := g.Clock; c != nil {
+		st.Time = c.Control.String()
+		st.Clock = &Clock{
+			Black: int64(c.Left(board.Black) / time.Millisecond),
+			White: int64(c.Left(board.White) / time.Millisecond),
+		}
+	}
+	return st
+}
+
+func summaryOf(e *entry) GameSummary {
+	g := e.game
+	s := GameSummary{
+		ID:     e.id,
+		Size:   g.Size,
+		Rule:   g.Rule.String(),
+		Black:  e.names[0],
+		White:  e.names[1],
+		Moves:  len(g.Moves),
+		Result: g.Result,
+	}
+	if !g.Over() {
+		s.Turn = colorName(g.Turn)
+	}
+	return s
+}
@@ -0,0 +1,243 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"../record"
+)
+
+const (
+	indexFile = "index.json"
+	idFormat  = "20060102-150405"
+)
+
+// 保存した棋譜一局分の情報
+type Entry struct {
+	ID     string    `json:"id"`
+	Date   time.Time `json:"date"`
+	Size   int       `json:"size"`
+	Rule   string    `json:"rule"`
+	Black  string    `json:"black"`
+	White  string    `json:"white"`
+	Result string    `json:"result"`
+	Moves  int       `json:"moves"`
+}
+
+// 棋譜はディレクトリにSGFで置き、一覧はindex.jsonに持つ
+type Archive struct {
+	dir     string
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// ディレクトリがなければ作る
+// index.jsonがなければSGFから作り直す
+func Open(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	a := &Archive{dir: dir}
+	data, err := ioutil.ReadFile(filepath.Join(dir, indexFile))
+	switch {
+	case os.IsNotExist(err):
+		if err := a.rebuild(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &a.entries); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *Archive) rebuild() error {
+	names, err := filepath.Glob(filepath.Join(a.dir, "*.sgf"))
+	if err != nil {
+		return err
+	}
+	a.entries = nil
+	for _, name := range names {
+		id := strings.TrimSuffix(filepath.Base(name), ".sgf")
+		g, err := a.Load(id)
+		if err != nil {
+			return fmt.Errorf("archive: %s: %v", name, err)
+		}
+		e := entryOf(id, g)
+		// IDは保存した時刻なので、読めればそれを使う
+		if len(id) >= len(idFormat) {
+			if t, err := time.ParseInLocation(idFormat, id[:len(idFormat)], time.Local); err == nil {
+				e.Date = t
+			}
+		}
+		if fi, err := os.Stat(name); err == nil && e.Date.IsZero() {
+			e.Date = fi.ModTime()
+		}
+		a.entries = append(a.entries, e)
+	}
+	sortEntries(a.entries)
+	return a.saveIndex()
+}
+
+func entryOf(id string, g *record.Game) Entry {
+	e := Entry{
+		ID:     id,
+		Size:   g.Size,
+		Rule:   g.Rule.String(),
+		Black:  g.Black,
+		White:  g.White,
+		Result: g.Result,
+		Moves:  len(record.MainLine(g)),
+	}
+	e.Date, _ = time.Parse("2006-01-02", g.Date)
+	return e
+}
+
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date)
+	})
+}
+
+func (a *Archive) saveIndex() error {
+	data, err := json.MarshalIndent(a.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(a.dir, indexFile+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(a.dir, indexFile))
+}
+
+func (a *Archive) path(id string) string {
+	return filepath.Join(a.dir, id+".sgf")
+}
+
+// 棋譜を保存して一覧に足す
+func (a *Archive) Add(g *record.Game, date time.Time) (Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id := date.Format(idFormat)
+	for i := 2; a.exists(id); i++ {
+		id = fmt.Sprintf("%s-%d", date.Format(idFormat), i)
+	}
+	if g.Date == "" {
+		g.Date = date.Format("2006-01-02")
+	}
+	if err := record.WriteFile(a.path(id), g); err != nil {
+		return Entry{}, err
+	}
+	e := entryOf(id, g)
+	e.Date = date
+	a.entries = append(a.entries, e)
+	sortEntries(a.entries)
+	return e, a.saveIndex()
+}
+
+func (a *Archive) exists(id string) bool {
+	for _, e := range a.entries {
+		if e.ID == id {
+			return true
+		}
+	}
+	_, err := os.Stat(a.path(id))
+	return err == nil
+}
+
+// 保存済みの棋譜を書き換える(注釈をつけたときなど)
+func (a *Archive) Update(id string, g *record.Game) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, e := range a.entries {
+		if e.ID != id {
+			continue
+		}
+		if err := record.WriteFile(a.path(id), g); err != nil {
+			return err
+		}
+		ne := entryOf(id, g)
+		ne.Date = e.Date
+		a.entries[i] = ne
+		return a.saveIndex()
+	}
+	return fmt.Errorf("archive: no game %q", id)
+}
+
+func (a *Archive) Load(id string) (*record.Game, error) {
+	f, err := os.Open(a.path(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return record.ReadSGF(f)
+}
+
+// 検索条件(ゼロ値の項目は条件にしない)
+type Query struct {
+	Size     int
+	Rule     string
+	Player   string // 黒か白のどちらか
+	Result   string // record.BlackWin など
+	Since    time.Time
+	Until    time.Time
+	MinMoves int
+	MaxMoves int
+}
+
+func (q Query) match(e Entry) bool {
+	switch {
+	case q.Size != 0 && e.Size != q.Size,
+		q.Rule != "" && e.Rule != q.Rule,
+		q.Player != "" && e.Black != q.Player && e.White != q.Player,
+		q.Result != "" && !strings.HasPrefix(e.Result, q.Result),
+		!q.Since.IsZero() && e.Date.Before(q.Since),
+		!q.Until.IsZero() && !e.Date.Before(q.Until),
+		q.MinMoves != 0 && e.Moves < q.MinMoves,
+		q.MaxMoves != 0 && e.Moves > q.MaxMoves:
+		return false
+	}
+	return true
+}
+
+// 条件に合う棋譜を日付順に返す
+func (a *Archive) List(q Query) []Entry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var out []Entry
+	for _, e := range a.entries {
+		if q.match(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// 条件に合う棋譜を一つのSGFファイル(コレクション)に書き出す
+func (a *Archive) Export(w io.Writer, q Query) (int, error) {
+	n := 0
+	for _, e := range a.List(q) {
+		g, err := a.Load(e.ID)
+		if err != nil {
+			return n, fmt.Errorf("archive: %s: %v", e.ID, err)
+		}
+		if err := record.WriteSGF(w, g); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
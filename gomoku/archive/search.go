@@ -0,0 +1,93 @@
+package archive
+
+import (
+	"fmt"
+
+	"../board"
+	"../record"
+)
+
+// 検索で見つかった棋譜と、その局面になった手数(0は開始局面)
+type Match struct {
+	Entry Entry
+	Move  int
+}
+
+// 対称な局面も含めて、同じ局面が現れた棋譜を探す
+func (a *Archive) SearchPosition(q Query, target *board.Board) ([]Match, error) {
+	q.Size = board.Size(target)
+	want, _ := board.CanonicalHash(target)
+	stones := countStones(target)
+	return a.search(q, nil, func(b *board.Board) bool {
+		if countStones(b) != stones {
+			return false
+		}
+		h, _ := board.CanonicalHash(b)
+		return h == want
+	})
+}
+
+// 盤上のどこかに形が現れた棋譜を探す
+func (a *Archive) SearchPattern(q Query, p *board.Pattern) ([]Match, error) {
+	found := func(b *board.Board) bool {
+		_, ok := p.Find(b)
+		return ok
+	}
+	// 石は取られないので、空点を含まない形は最後の局面になければそれまでにも現れない
+	for _, c := range p.Cells {
+		if c.Which == board.Space {
+			return a.search(q, nil, found)
+		}
+	}
+	return a.search(q, found, found)
+}
+
+// 本譜を一手ずつ並べ、最初にmatchした局面を返す
+// finalが最後の局面にfalseを返す棋譜は並べずに飛ばす
+func (a *Archive) search(q Query, final func(*board.Board) bool, match func(*board.Board) bool) ([]Match, error) {
+	var out []Match
+	for _, e := range a.List(q) {
+		g, err := a.Load(e.ID)
+		if err != nil {
+			return out, fmt.Errorf("archive: %s: %v", e.ID, err)
+		}
+		b, err := record.Position(g, g.Root)
+		if err != nil {
+			return out, fmt.Errorf("archive: %s: %v", e.ID, err)
+		}
+		moves := record.MainLine(g)
+		if final != nil {
+			last := board.Copy(b)
+			for _, m := range moves {
+				board.Set(last, m.X, m.Y, m.Which)
+			}
+			if !final(last) {
+				continue
+			}
+		}
+		for i := 0; i <= len(moves); i++ {
+			if i > 0 {
+				m := moves[i-1]
+				board.Set(b, m.X, m.Y, m.Which)
+			}
+			if match(b) {
+				out = append(out, Match{Entry: e, Move: i})
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func countStones(b *board.Board) int {
+	n := 0
+	size := board.Size(b)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if board.At(b, x, y) != board.Space {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -4,11 +4,22 @@ import (
 	"log"
 )
 
+const (
+	Space = 0
+	Black = 1
+	White = 2
+)
+
 type Board struct {
 	board [][]int
 	size  int
 }
 
+type Point struct {
+	X int
+	Y int
+}
+
 func New(size int) *Board {
 	b := new(Board)
 	b.size = size
@@ -28,6 +39,61 @@ func New13() *Board { return New(13) }
 
 func New9() *Board { return New(9) }
 
+func Size(b *Board) int { return b.size }
+
+// 盤外は-1を返す
+func At(b *Board, x int, y int) int {
+	if !inBoard(b, x, y) {
+		return -1
+	}
+	return b.board[y][x]
+}
+
+func inBoard(b *Board, x int, y int) bool {
+	return x >= 0 && x < b.size && y >= 0 && y < b.size
+}
+
+func Opponent(which int) int {
+	if which == Black {
+		return White
+	}
+	return Black
+}
+
+func Copy(b *Board) *Board {
+	c := New(b.size)
+	for y := range b.board {
+		copy(c.board[y], b.board[y])
+	}
+	return c
+}
+
+// 探索用にログを出さずに石を置く(which=Spaceで取り除く)
+func Set(b *Board, x int, y int, which int) {
+	b.board[y][x] = which
+}
+
+// (x, y)の石を含んで五つ以上並んでいるか
+func FiveAt(b *Board, x int, y int) bool {
+	which := At(b, x, y)
+	if which <= 0 {
+		return false
+	}
+	for _, d := range dirs {
+		line := 1
+		for i := 1; At(b, x+d.X*i, y+d.Y*i) == which; i++ {
+			line++
+		}
+		for i := 1; At(b, x-d.X*i, y-d.Y*i) == which; i++ {
+			line++
+		}
+		if line >= 5 {
+			return true
+		}
+	}
+	return false
+}
+
 func PutPos(b *Board, posX int, posY int, which int) bool {
 	if b.board[posY][posX] != 0 {
 		return false
@@ -0,0 +1,40 @@
+package board
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type Move struct {
+	X     int
+	Y     int
+	Which int
+}
+
+// 座標は列をa, b, c...、行を上から1, 2, 3...で表す(例: 左上がa1)
+func FormatPoint(p Point) string {
+	return fmt.Sprintf("%c%d", 'a'+p.X, p.Y+1)
+}
+
+func ParsePoint(s string) (Point, error) {
+	if len(s) < 2 || s[0] < 'a' || s[0] > 'z' {
+		return Point{}, fmt.Errorf("board: bad point %q", s)
+	}
+	y, err := strconv.Atoi(s[1:])
+	if err != nil || y < 1 {
+		return Point{}, fmt.Errorf("board: bad point %q", s)
+	}
+	return Point{int(s[0] - 'a'), y - 1}, nil
+}
+
+// 黒から交互に打った手順を盤に並べる
+func Replay(size int, moves []Move) (*Board, error) {
+	b := New(size)
+	for i, m := range moves {
+		if At(b, m.X, m.Y) != Space {
+			return nil, fmt.Errorf("board: move %d at %s is not playable", i+1, FormatPoint(Point{m.X, m.Y}))
+		}
+		Set(b, m.X, m.Y, m.Which)
+	}
+	return b, nil
+}
@@ -0,0 +1,84 @@
+package board
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// 盤の対称変換(回転4通り×裏返し)の数
+const Symmetries = 8
+
+var (
+	zobristMu sync.Mutex
+	zobrist   = map[int][][2]uint64{}
+)
+
+// 盤の大きさごとに同じ乱数表を作る
+func zobristTable(size int) [][2]uint64 {
+	zobristMu.Lock()
+	defer zobristMu.Unlock()
+	if t, ok := zobrist[size]; ok {
+		return t
+	}
+	r := rand.New(rand.NewSource(int64(size)))
+	t := make([][2]uint64, size*size)
+	for i := range t {
+		t[i] = [2]uint64{r.Uint64(), r.Uint64()}
+	}
+	zobrist[size] = t
+	return t
+}
+
+func Hash(b *Board) uint64 {
+	return hashSym(b, 0)
+}
+
+// 対称な局面で同じ値になるハッシュと、そのときの変換の番号
+func CanonicalHash(b *Board) (uint64, int) {
+	best, bestSym := hashSym(b, 0), 0
+	for sym := 1; sym < Symmetries; sym++ {
+		if h := hashSym(b, sym); h < best {
+			best, bestSym = h, sym
+		}
+	}
+	return best, bestSym
+}
+
+func hashSym(b *Board, sym int) uint64 {
+	t := zobristTable(b.size)
+	var h uint64
+	for y := 0; y < b.size; y++ {
+		for x := 0; x < b.size; x++ {
+			w := b.board[y][x]
+			if w == Space {
+				continue
+			}
+			p := Transform(Point{x, y}, sym, b.size)
+			h ^= t[p.Y*b.size+p.X][w-1]
+		}
+	}
+	return h
+}
+
+// symの下位2ビットは回転の回数、4のビットは左右の裏返し
+func Transform(p Point, sym int, size int) Point {
+	x, y := p.X, p.Y
+	if sym&4 != 0 {
+		x = size - 1 - x
+	}
+	for i := 0; i < sym&3; i++ {
+		x, y = size-1-y, x
+	}
+	return Point{x, y}
+}
+
+func InverseTransform(p Point, sym int, size int) Point {
+	x, y := p.X, p.Y
+	for i := 0; i < (4-sym&3)%4; i++ {
+		x, y = size-1-y, x
+	}
+	if sym&4 != 0 {
+		x = size - 1 - x
+	}
+	return Point{x, y}
+}
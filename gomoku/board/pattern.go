@@ -0,0 +1,156 @@
+package board
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// 盤上のどこかに現れる局所的な形
+// WhichがSpaceのマスは空いていなければならない
+type Pattern struct {
+	Cells    []Move
+	variants [][]Move // 対称変換して左上に寄せたもの(同じ形は除く)
+	hash     uint64
+}
+
+// 行を/で区切り、xは黒、oは白、.は空点、-はどちらでもよいマス
+// 例: "-x-/.x./-x-"
+func ParsePattern(s string) (*Pattern, error) {
+	var cells []Move
+	for y, row := range strings.Split(strings.TrimSpace(s), "/") {
+		for x, c := range row {
+			switch c {
+			case 'x':
+				cells = append(cells, Move{x, y, Black})
+			case 'o':
+				cells = append(cells, Move{x, y, White})
+			case '.':
+				cells = append(cells, Move{x, y, Space})
+			case '-':
+			default:
+				return nil, fmt.Errorf("board: bad pattern %q", s)
+			}
+		}
+	}
+	return NewPattern(cells)
+}
+
+// 盤上の石をすべて形にする
+func PatternOf(b *Board) (*Pattern, error) {
+	var cells []Move
+	for y := 0; y < b.size; y++ {
+		for x := 0; x < b.size; x++ {
+			if w := b.board[y][x]; w != Space {
+				cells = append(cells, Move{x, y, w})
+			}
+		}
+	}
+	return NewPattern(cells)
+}
+
+func NewPattern(cells []Move) (*Pattern, error) {
+	stones := 0
+	for _, c := range cells {
+		if c.Which != Space {
+			stones++
+		}
+	}
+	if stones == 0 {
+		return nil, fmt.Errorf("board: pattern has no stones")
+	}
+
+	p := &Pattern{Cells: cells}
+	seen := map[uint64]bool{}
+	for sym := 0; sym < Symmetries; sym++ {
+		v := normalize(cells, sym)
+		h := cellsHash(v)
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		p.variants = append(p.variants, v)
+		if len(p.variants) == 1 || h < p.hash {
+			p.hash = h
+		}
+	}
+	return p, nil
+}
+
+// 変換してから左上を原点に寄せ、石を先に並べる
+func normalize(cells []Move, sym int) []Move {
+	v := make([]Move, len(cells))
+	minX, minY := 0, 0
+	for i, c := range cells {
+		// 大きさ1の盤として変換すると、原点のまわりの回転と裏返しになる
+		q := Transform(Point{c.X, c.Y}, sym, 1)
+		v[i] = Move{q.X, q.Y, c.Which}
+		if i == 0 || q.X < minX {
+			minX = q.X
+		}
+		if i == 0 || q.Y < minY {
+			minY = q.Y
+		}
+	}
+	for i := range v {
+		v[i].X -= minX
+		v[i].Y -= minY
+	}
+	sort.Slice(v, func(i, j int) bool {
+		if (v[i].Which == Space) != (v[j].Which == Space) {
+			return v[j].Which == Space
+		}
+		if v[i].Y != v[j].Y {
+			return v[i].Y < v[j].Y
+		}
+		return v[i].X < v[j].X
+	})
+	return v
+}
+
+func cellsHash(cells []Move) uint64 {
+	h := fnv.New64a()
+	for _, c := range cells {
+		fmt.Fprintf(h, "%d,%d,%d;", c.X, c.Y, c.Which)
+	}
+	return h.Sum64()
+}
+
+// 対称な形で同じ値になるハッシュ
+func (p *Pattern) Hash() uint64 {
+	return p.hash
+}
+
+// 盤上で形が見つかれば、その石と空点の位置を返す
+func (p *Pattern) Find(b *Board) ([]Point, bool) {
+	for _, v := range p.variants {
+		anchor := v[0]
+		for y := 0; y < b.size; y++ {
+			for x := 0; x < b.size; x++ {
+				if b.board[y][x] != anchor.Which {
+					continue
+				}
+				dx, dy := x-anchor.X, y-anchor.Y
+				if !matchAt(b, v, dx, dy) {
+					continue
+				}
+				pts := make([]Point, len(v))
+				for i, c := range v {
+					pts[i] = Point{c.X + dx, c.Y + dy}
+				}
+				return pts, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func matchAt(b *Board, cells []Move, dx int, dy int) bool {
+	for _, c := range cells {
+		if At(b, c.X+dx, c.Y+dy) != c.Which {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package board
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 局面を一行の文字列で表す
+//
+//	6x6/5ox6/13/... b freestyle
+//
+// 一つ目は上の行から/で区切った盤面で、数字は空点の数、xは黒、oは白
+// 行の数が盤の大きさになる
+// 二つ目は手番(bかw)で、省略すると石の数から決める
+// 三つ目はルールで、省略するとfreestyle
+func FormatPosition(b *Board, toMove int, rule Rule) string {
+	rows := make([]string, b.size)
+	for y := 0; y < b.size; y++ {
+		var row []byte
+		empty := 0
+		for x := 0; x < b.size; x++ {
+			w := b.board[y][x]
+			if w == Space {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				row = strconv.AppendInt(row, int64(empty), 10)
+				empty = 0
+			}
+			if w == Black {
+				row = append(row, 'x')
+			} else {
+				row = append(row, 'o')
+			}
+		}
+		if empty > 0 {
+			row = strconv.AppendInt(row, int64(empty), 10)
+		}
+		rows[y] = string(row)
+	}
+	side := "b"
+	if toMove == White {
+		side = "w"
+	}
+	return strings.Join(rows, "/") + " " + side + " " + rule.String()
+}
+
+func ParsePosition(s string) (*Board, int, Rule, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 3 {
+		return nil, 0, Freestyle, fmt.Errorf("board: bad position %q", s)
+	}
+	rows := strings.Split(fields[0], "/")
+	size := len(rows)
+	if size < 5 || size > 26 {
+		return nil, 0, Freestyle, fmt.Errorf("board: bad position size %d", size)
+	}
+
+	b := New(size)
+	blacks, whites := 0, 0
+	for y, row := range rows {
+		x := 0
+		for i := 0; i < len(row); i++ {
+			c := row[i]
+			switch {
+			case c >= '0' && c <= '9':
+				j := i
+				for j < len(row) && row[j] >= '0' && row[j] <= '9' {
+					j++
+				}
+				n, _ := strconv.Atoi(row[i:j])
+				x += n
+				i = j - 1
+			case c == 'x' || c == 'o':
+				if x >= size {
+					return nil, 0, Freestyle, fmt.Errorf("board: position row %d is too long", y+1)
+				}
+				if c == 'x' {
+					b.board[y][x] = Black
+					blacks++
+				} else {
+					b.board[y][x] = White
+					whites++
+				}
+				x++
+			default:
+				return nil, 0, Freestyle, fmt.Errorf("board: bad position row %q", row)
+			}
+		}
+		if x != size {
+			return nil, 0, Freestyle, fmt.Errorf("board: position row %d has %d points, want %d", y+1, x, size)
+		}
+	}
+
+	toMove := Black
+	if blacks > whites {
+		toMove = White
+	}
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "b":
+			toMove = Black
+		case "w":
+			toMove = White
+		default:
+			return nil, 0, Freestyle, fmt.Errorf("board: bad side to move %q", fields[1])
+		}
+	}
+	rule := Freestyle
+	if len(fields) > 2 {
+		r, err := ParseRule(fields[2])
+		if err != nil {
+			return nil, 0, Freestyle, err
+		}
+		rule = r
+	}
+	return b, toMove, rule, nil
+}
@@ -0,0 +1,68 @@
+package board
+
+import "testing"
+
+func TestPositionRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		toMove int
+		rule   Rule
+		out    string // 空ならinと同じ
+	}{
+		{"5/5/5/5/5 b freestyle", Black, Freestyle, ""},
+		{"5/5/2x2/5/5 w renju", White, Renju, ""},
+		{"x4/1o3/5/5/4x w standard", White, Standard, ""},
+		{"15/15/15/15/15/15/15/7x7/15/15/15/15/15/15/15 w freestyle", White, Freestyle, ""},
+		{"26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/26/o25 b freestyle", Black, Freestyle, ""},
+		// 手番を省略すると石の数から決める
+		{"5/5/2x2/5/5", White, Freestyle, "5/5/2x2/5/5 w freestyle"},
+		{"5/1o3/2x2/5/5", Black, Freestyle, "5/1o3/2x2/5/5 b freestyle"},
+		// 石の数と合わない手番でも書いてあればそれを使う
+		{"5/5/2x2/5/5 b", Black, Freestyle, "5/5/2x2/5/5 b freestyle"},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			b, toMove, rule, err := ParsePosition(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if toMove != tc.toMove || rule != tc.rule {
+				t.Fatalf("toMove, rule = %d, %v, want %d, %v", toMove, rule, tc.toMove, tc.rule)
+			}
+			want := tc.out
+			if want == "" {
+				want = tc.in
+			}
+			got := FormatPosition(b, toMove, rule)
+			if got != want {
+				t.Fatalf("FormatPosition = %q, want %q", got, want)
+			}
+			// 書き出したものを読み直しても同じ
+			b2, toMove2, rule2, err := ParsePosition(got)
+			if err != nil || toMove2 != toMove || rule2 != rule || Hash(b2) != Hash(b) {
+				t.Fatalf("reparse of %q differs: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestParsePositionErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"   ",
+		"5/5/5/5/5 b freestyle extra",
+		"4/4/4/4",    // 小さすぎる
+		"5/5/5/5/5/", // 最後の行が空
+		"27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27/27",
+		"6/5/5/5/5",   // 長すぎる行
+		"4/5/5/5/5",   // 短すぎる行
+		"5x/5/5/5/5",  // 石が盤の外
+		"5/5/2z2/5/5", // 知らない文字
+		"5/5/2X2/5/5",
+		"5/5/5/5/5 x",
+		"5/5/5/5/5 b gomoku",
+	} {
+		if _, _, _, err := ParsePosition(in); err == nil {
+			t.Errorf("ParsePosition(%q) accepted", in)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package board
+
+import (
+	"fmt"
+)
+
+// 勝ちの判定は今のところFreestyle(五以上で勝ち)だけ
+type Rule int
+
+const (
+	Freestyle Rule = iota
+	Standard       // ちょうど五で勝ち
+	Renju
+)
+
+var ruleNames = map[Rule]string{
+	Freestyle: "freestyle",
+	Standard:  "standard",
+	Renju:     "renju",
+}
+
+func (r Rule) String() string {
+	if s, ok := ruleNames[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("Rule(%d)", int(r))
+}
+
+func ParseRule(s string) (Rule, error) {
+	for r, name := range ruleNames {
+		if name == s {
+			return r, nil
+		}
+	}
+	return Freestyle, fmt.Errorf("board: unknown rule %q", s)
+}
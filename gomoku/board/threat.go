@@ -0,0 +1,105 @@
+package board
+
+import (
+	"sort"
+)
+
+const (
+	ThreatThree = iota // 活三
+	ThreatFour         // 四(活四も含む)
+)
+
+type Threat struct {
+	Kind   int
+	Which  int
+	Stones []Point
+}
+
+// 縦・横・斜め2方向
+var dirs = [4]Point{{1, 0}, {0, 1}, {1, 1}, {1, -1}}
+
+// 盤上の全ての活三と四を探す
+// which が0なら両者分を返す
+func Threats(b *Board, which int) []Threat {
+	var threats []Threat
+	seen := map[string]bool{}
+	add := func(t Threat) {
+		sort.Slice(t.Stones, func(i, j int) bool {
+			if t.Stones[i].Y != t.Stones[j].Y {
+				return t.Stones[i].Y < t.Stones[j].Y
+			}
+			return t.Stones[i].X < t.Stones[j].X
+		})
+		key := threatKey(t)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		threats = append(threats, t)
+	}
+
+	for y := 0; y < b.size; y++ {
+		for x := 0; x < b.size; x++ {
+			for _, d := range dirs {
+				// 五マスの窓に自石4つと空点1つなら四
+				if w, ok := window(b, x, y, d, 5); ok {
+					if c, stones := lineCount(w); c != 0 && (which == 0 || c == which) && len(stones) == 4 {
+						add(Threat{Kind: ThreatFour, Which: c, Stones: stones})
+					}
+				}
+				// 六マスの窓で両端が空点、内側4マスに自石3つと空点1つなら活三
+				if w, ok := window(b, x, y, d, 6); ok {
+					if w[0].v != 0 || w[5].v != 0 {
+						continue
+					}
+					if c, stones := lineCount(w[1:5]); c != 0 && (which == 0 || c == which) && len(stones) == 3 {
+						add(Threat{Kind: ThreatThree, Which: c, Stones: stones})
+					}
+				}
+			}
+		}
+	}
+	return threats
+}
+
+type cell struct {
+	p Point
+	v int
+}
+
+func window(b *Board, x int, y int, d Point, n int) ([]cell, bool) {
+	if !inBoard(b, x+d.X*(n-1), y+d.Y*(n-1)) {
+		return nil, false
+	}
+	w := make([]cell, n)
+	for i := 0; i < n; i++ {
+		p := Point{x + d.X*i, y + d.Y*i}
+		w[i] = cell{p, b.board[p.Y][p.X]}
+	}
+	return w, true
+}
+
+// 窓の中が一色だけならその色と石の位置を返す
+func lineCount(w []cell) (int, []Point) {
+	which := 0
+	var stones []Point
+	for _, c := range w {
+		if c.v == 0 {
+			continue
+		}
+		if which != 0 && c.v != which {
+			return 0, nil
+		}
+		which = c.v
+		stones = append(stones, c.p)
+	}
+	return which, stones
+}
+
+func threatKey(t Threat) string {
+	key := []byte{byte(t.Kind), byte(t.Which)}
+	for _, p := range t.Stones {
+		key = append(key, byte(p.X), byte(p.Y))
+	}
+	return string(key)
+}
@@ -0,0 +1,208 @@
+package book
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sort"
+
+	"../board"
+)
+
+var ErrSizeMismatch = errors.New("book: board sizes do not match")
+
+// 定跡に入れる手数の既定値
+const DefaultPly = 12
+
+// 候補手の座標は対称変換した基準の向きで持つ
+type Candidate struct {
+	Move   board.Point `json:"move"`
+	Weight int         `json:"weight"`
+	Wins   int         `json:"wins"`
+	Losses int         `json:"losses"`
+	Draws  int         `json:"draws"`
+}
+
+type Entry struct {
+	Candidates []*Candidate `json:"candidates"`
+}
+
+type Book struct {
+	Size    int               `json:"size"`
+	Entries map[uint64]*Entry `json:"entries"`
+}
+
+func New(size int) *Book {
+	return &Book{Size: size, Entries: map[uint64]*Entry{}}
+}
+
+// 局面でpに打った結果(1:打った側の勝ち, 0:引き分けか勝負なし, -1:負け)を記録する
+func Add(bk *Book, b *board.Board, p board.Point, result int) {
+	h, sym := board.CanonicalHash(b)
+	e := bk.Entries[h]
+	if e == nil {
+		e = &Entry{}
+		bk.Entries[h] = e
+	}
+	c := Candidate{Move: board.Transform(p, sym, board.Size(b)), Weight: 1}
+	switch {
+	case result > 0:
+		c.Wins = 1
+	case result < 0:
+		c.Losses = 1
+	default:
+		c.Draws = 1
+	}
+	addCandidate(e, c)
+}
+
+func addCandidate(e *Entry, c Candidate) {
+	var dst *Candidate
+	for _, d := range e.Candidates {
+		if d.Move == c.Move {
+			dst = d
+			break
+		}
+	}
+	if dst == nil {
+		dst = &Candidate{Move: c.Move}
+		e.Candidates = append(e.Candidates, dst)
+	}
+	dst.Weight += c.Weight
+	dst.Wins += c.Wins
+	dst.Losses += c.Losses
+	dst.Draws += c.Draws
+}
+
+// 一局分の手順を最初のply手まで定跡に入れる
+func AddGame(bk *Book, moves []board.Move, ply int) error {
+	last, err := board.Replay(bk.Size, moves)
+	if err != nil {
+		return err
+	}
+	// 最後の手で五ができていればその手を打った側の勝ち
+	winner := board.Space
+	if n := len(moves); n > 0 && board.FiveAt(last, moves[n-1].X, moves[n-1].Y) {
+		winner = moves[n-1].Which
+	}
+
+	b := board.New(bk.Size)
+	for i, m := range moves {
+		if i >= ply {
+			break
+		}
+		result := 0
+		if winner == m.Which {
+			result = 1
+		} else if winner != board.Space {
+			result = -1
+		}
+		Add(bk, b, board.Point{X: m.X, Y: m.Y}, result)
+		board.Set(b, m.X, m.Y, m.Which)
+	}
+	return nil
+}
+
+// 局面の候補手を実際の盤の向きで返す(重みの大きい順)
+func Lookup(bk *Book, b *board.Board) []Candidate {
+	if bk == nil || board.Size(b) != bk.Size {
+		return nil
+	}
+	h, sym := board.CanonicalHash(b)
+	e := bk.Entries[h]
+	if e == nil {
+		return nil
+	}
+	cands := make([]Candidate, 0, len(e.Candidates))
+	for _, c := range e.Candidates {
+		cc := *c
+		cc.Move = board.InverseTransform(c.Move, sym, bk.Size)
+		// 対称な局面では向きが一意に決まらないので空点か確かめる
+		if board.At(b, cc.Move.X, cc.Move.Y) != board.Space {
+			continue
+		}
+		cands = append(cands, cc)
+	}
+	sort.SliceStable(cands, func(i, j int) bool {
+		return cands[i].Weight > cands[j].Weight
+	})
+	return cands
+}
+
+// 重みと勝率に応じて候補手を一つ選ぶ
+func Choose(bk *Book, b *board.Board, r *rand.Rand) (board.Point, bool) {
+	cands := Lookup(bk, b)
+	total := 0
+	scores := make([]int, len(cands))
+	for i, c := range cands {
+		// 負けの多い手は選ばない
+		s := c.Weight + c.Wins*2 - c.Losses*2
+		if s < 1 {
+			s = 0
+		}
+		scores[i] = s
+		total += s
+	}
+	if total == 0 {
+		return board.Point{}, false
+	}
+	n := r.Intn(total)
+	for i, s := range scores {
+		if n < s {
+			return cands[i].Move, true
+		}
+		n -= s
+	}
+	return board.Point{}, false
+}
+
+// 二つの定跡を足し合わせる
+// 盤の大きさが違えば座標の意味が変わるので足せない
+func Merge(a *Book, b *Book) (*Book, error) {
+	if a.Size != b.Size {
+		return nil, ErrSizeMismatch
+	}
+	out := New(a.Size)
+	for _, src := range []*Book{a, b} {
+		for h, e := range src.Entries {
+			dst := out.Entries[h]
+			if dst == nil {
+				dst = &Entry{}
+				out.Entries[h] = dst
+			}
+			for _, c := range e.Candidates {
+				addCandidate(dst, *c)
+			}
+		}
+	}
+	return out, nil
+}
+
+// ファイルがなければ空の定跡を返す
+func Load(path string, size int) (*Book, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return New(size), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	bk := New(size)
+	if err := json.Unmarshal(data, bk); err != nil {
+		return nil, err
+	}
+	if bk.Entries == nil {
+		bk.Entries = map[uint64]*Entry{}
+	}
+	return bk, nil
+}
+
+func Save(bk *Book, path string) error {
+	data, err := json.Marshal(bk)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
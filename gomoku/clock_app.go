@@ -0,0 +1,127 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package main
+
+import (
+	"log"
+	"os"
+
+	"./game"
+
+	"golang.org/x/mobile/event/size"
+	"golang.org/x/mobile/exp/f32"
+	"golang.org/x/mobile/exp/sprite"
+)
+
+var (
+	// 持ち時間。なければnil
+	// ネットワーク対局ではサーバーから届いた残り時間を表示するだけ
+	gameClock   *game.Clock
+	clockBlackN *sprite.Node
+	clockWhiteN *sprite.Node
+)
+
+// GOMOKU_TIMEに持ち時間("10m", "5m+3s", "10m/30s*5"など)があれば時計をつける
+func newLocalClock() *game.Clock {
+	s := os.Getenv("GOMOKU_TIME")
+	if s == "" {
+		return nil
+	}
+	tc, err := game.ParseTimeControl(s)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	c := game.NewClock(tc)
+	c.Start(BLACK)
+	return c
+}
+
+// 打つ前に時間が残っているか
+func clockAllows() bool {
+	return mode != modeGame || gameClock == nil || gameClock.Expired() == SPACE
+}
+
+func punchClock() {
+	if mode != modeGame || gameClock == nil {
+		return
+	}
+	if err := gameClock.Punch(); err != nil {
+		log.Println(err)
+	}
+}
+
+// 対局中に手番の側の時間が切れたら負けにする
+func checkClock() {
+	if mode != modeGame || gameClock == nil || endFlag {
+		return
+	}
+	loser := gameClock.Expired()
+	if loser == SPACE {
+		return
+	}
+	gameClock.Pause()
+	endFlag = true
+	endResult = game.TimeLoss(loser)
+	log.Println("時間切れ:", endResult)
+	analyzeGame()
+}
+
+// 盤の下に黒と白の残り時間のバーを出す
+// 秒読みに入ったら、一回分の秒読みに対する残りを出す
+func updateClockBars(sz size.Event) {
+	if clockBlackN == nil {
+		return
+	}
+	c := gameClock
+	if mode != modeGame && !netMode() {
+		c = nil
+	}
+	w := float32(sz.WidthPt) / 2
+	h := float32(8)
+	top := float32((sz.HeightPt+sz.WidthPt)/2) + h
+	for i, n := range []*sprite.Node{clockBlackN, clockWhiteN} {
+		p := float32(0)
+		if c != nil {
+			p = clockRate(c, i+1)
+		}
+		eng.SetTransform(n, f32.Affine{
+			{w * p, 0, w * float32(i)},
+			{0, h, top},
+		})
+	}
+}
+
+func clockRate(c *game.Clock, which int) float32 {
+	pt := c.Remaining(which)
+	left, full := pt.Main, c.Control.Main
+	if pt.Overtime {
+		left, full = pt.Period, c.Control.Period
+	}
+	if full <= 0 {
+		return 0
+	}
+	p := float32(left) / float32(full)
+	if p > 1 {
+		// フィッシャーで増えた分ははみ出さない
+		p = 1
+	}
+	return p
+}
+
+// 時計のバーは形勢バーと同じ画像を使う
+func loadClockNodes() {
+	clockBlackN = newNode()
+	eng.SetSubTex(clockBlackN, evalTexs[texEvalBlack])
+	clockWhiteN = newNode()
+	eng.SetSubTex(clockWhiteN, evalTexs[texEvalWhite])
+}
+
+// 保存しておいた時計で対局を続ける
+func restoreClock(g *game.Game) {
+	gameClock = g.Clock
+	if gameClock != nil && !g.Over() {
+		gameClock.Resume()
+	}
+}
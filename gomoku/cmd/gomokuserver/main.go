@@ -0,0 +1,76 @@
+// gomokuserver はネットワーク対局を仲介するサーバー
+//
+//	$ gomokuserver [-addr :7650] [-ratings ratings.json] [-grace 1m] [-clock pause|run]
+//		[-corr dir] [-deadline 72h] [-http :8080] [-archive dir]
+//
+// アプリは環境変数GOMOKU_SERVERにこのサーバーのアドレスを入れて起動する
+// -ratingsを指定すると、ロビーに登録した対局者のレーティングを記録する
+// -clock runなら、切れた対局者を待つ間も持ち時間を減らす
+// -corrを指定すると通信対局ができ、対局をそのディレクトリに保存する
+// -httpを指定すると、そのアドレスでHTTPのAPI(apiパッケージ)も受け付ける
+// -archiveを指定すると、HTTPのAPIで終局した対局をそのアーカイブに保存する
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"../../api"
+	"../../archive"
+	"../../network"
+	"../../rating"
+)
+
+func main() {
+	addr := flag.String("addr", ":"+network.DefaultPort, "listen address")
+	ratings := flag.String("ratings", "", "ratings file")
+	grace := flag.Duration("grace", network.DefaultGrace, "how long to wait for a disconnected player")
+	clocks := flag.String("clock", "pause", "clock while a player is disconnected (pause or run)")
+	corr := flag.String("corr", "", "correspondence games directory")
+	deadline := flag.Duration("deadline", network.DefaultMoveDeadline, "time allowed per correspondence move")
+	httpAddr := flag.String("http", "", "HTTP API listen address")
+	archiveDir := flag.String("archive", "", "archive directory for games finished through the HTTP API")
+	flag.Parse()
+
+	s := network.NewServer()
+	s.Grace = *grace
+	switch *clocks {
+	case "pause":
+		s.Clocks = network.ClockPause
+	case "run":
+		s.Clocks = network.ClockRun
+	default:
+		log.Fatalf("unknown clock policy %q", *clocks)
+	}
+	if *ratings != "" {
+		r, err := rating.LoadRegistry(*ratings)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.Ratings = r
+		s.RatingsFile = *ratings
+	}
+	if *corr != "" {
+		s.MoveDeadline = *deadline
+		if err := s.OpenCorrespondence(*corr); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *httpAddr != "" {
+		h := api.NewServer()
+		if *archiveDir != "" {
+			a, err := archive.Open(*archiveDir)
+			if err != nil {
+				log.Fatal(err)
+			}
+			h.Archive = a
+		}
+		go func() {
+			log.Printf("HTTP API on %s", *httpAddr)
+			log.Fatal(http.ListenAndServe(*httpAddr, h))
+		}()
+	}
+	log.Printf("listening on %s", *addr)
+	log.Fatal(s.ListenAndServe(*addr))
+}
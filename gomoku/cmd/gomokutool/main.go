@@ -0,0 +1,795 @@
+// gomokutool は画面を使わずに棋譜を扱うためのコマンド
+//
+//	$ gomokutool review [-size 13] [-from position] game.txt|game.sgf|game.psq
+//	$ gomokutool puzzle puzzles.txt
+//	$ gomokutool book build [-size 13] [-ply 12] -o book.json games.txt...
+//	$ gomokutool book merge -o book.json a.json b.json...
+//	$ gomokutool convert [-size 15] game.psq game.sgf
+//	$ gomokutool archive list [-dir dir] [-size 13] [-player name] [-result B+]
+//	$ gomokutool archive export [-dir dir] [-size 13] [-player name] [-result B+] -o games.sgf
+//	$ gomokutool archive search [-dir dir] [-size 13] -position position | -pattern "xx.x"
+//	$ gomokutool rating [-dir dir] [-o ratings.json]
+//	$ gomokutool corr [-server addr] [-tokens file] -name me list|new|show id|move id h8|resign id
+//	$ gomokutool bot [-server addr] [-level n] [-size 15] [-rule freestyle] [-time 5m+3s] name
+//	$ gomokutool tournament new [-format swiss] [-rounds n] -o t.json name players...
+//	$ gomokutool tournament pair|play|table t.json
+//	$ gomokutool tournament result t.json black white B+
+//
+// 棋譜ファイルは拡張子で形式を決める(.sgf, .lib, .psq, .pos)
+// それ以外は一行に一局、空白区切りの座標を並べたテキストとして読む
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"../../ai"
+	"../../archive"
+	"../../board"
+	"../../book"
+	"../../game"
+	"../../network"
+	"../../puzzle"
+	"../../rating"
+	"../../record"
+	"../../tournament"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "review":
+		review(os.Args[2:])
+	case "puzzle":
+		verifyPuzzles(os.Args[2:])
+	case "convert":
+		convert(os.Args[2:])
+	case "rating":
+		ratings(os.Args[2:])
+	case "corr":
+		correspondence(os.Args[2:])
+	case "bot":
+		runBot(os.Args[2:])
+	case "tournament":
+		if len(os.Args) < 3 {
+			usage()
+		}
+		switch os.Args[2] {
+		case "new":
+			newTournament(os.Args[3:])
+		case "pair", "play", "table", "result":
+			runTournament(os.Args[2], os.Args[3:])
+		default:
+			usage()
+		}
+	case "archive":
+		if len(os.Args) < 3 {
+			usage()
+		}
+		switch os.Args[2] {
+		case "list":
+			listArchive(os.Args[3:])
+		case "export":
+			exportArchive(os.Args[3:])
+		case "search":
+			searchArchive(os.Args[3:])
+		default:
+			usage()
+		}
+	case "book":
+		if len(os.Args) < 3 {
+			usage()
+		}
+		switch os.Args[2] {
+		case "build":
+			buildBook(os.Args[3:])
+		case "merge":
+			mergeBooks(os.Args[3:])
+		default:
+			usage()
+		}
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gomokutool review [-size n] [-from position] [file]")
+	fmt.Fprintln(os.Stderr, "       gomokutool puzzle file...")
+	fmt.Fprintln(os.Stderr, "       gomokutool book build [-size n] [-ply n] -o out games...")
+	fmt.Fprintln(os.Stderr, "       gomokutool book merge -o out books...")
+	fmt.Fprintln(os.Stderr, "       gomokutool convert [-size n] in out")
+	fmt.Fprintln(os.Stderr, "       gomokutool archive list [-dir dir] [-size n] [-player name] [-result r]")
+	fmt.Fprintln(os.Stderr, "       gomokutool archive export [-dir dir] [-size n] [-player name] [-result r] -o out")
+	fmt.Fprintln(os.Stderr, "       gomokutool archive search [-dir dir] [-size n] -position pos | -pattern pat")
+	fmt.Fprintln(os.Stderr, "       gomokutool rating [-dir dir] [-o ratings.json]")
+	fmt.Fprintln(os.Stderr, "       gomokutool corr [-server addr] [-tokens file] -name me list|new|show id|move id point|resign id")
+	fmt.Fprintln(os.Stderr, "       gomokutool bot [-server addr] [-level n] [-size n] [-rule r] [-time t] name")
+	fmt.Fprintln(os.Stderr, "       gomokutool tournament new [-format f] [-rounds n] -o out name players...")
+	fmt.Fprintln(os.Stderr, "       gomokutool tournament pair|play|table file")
+	fmt.Fprintln(os.Stderr, "       gomokutool tournament result file black white result")
+	os.Exit(2)
+}
+
+// 棋譜を検討して一手ずつ注釈をつけて出力する
+func review(args []string) {
+	fs := flag.NewFlagSet("review", flag.ExitOnError)
+	size := fs.Int("size", 13, "board size")
+	from := fs.String("from", "", "starting position in board.ParsePosition notation")
+	fs.Parse(args)
+
+	var (
+		moves []board.Move
+		start *board.Board
+	)
+	if name := fs.Arg(0); isRecordFile(name) {
+		games, err := record.ReadFile(name, *size)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(games) == 0 {
+			log.Fatalf("%s: no game", name)
+		}
+		*size = games[0].Size
+		moves = record.MainLine(games[0])
+		start, err = record.Position(games[0], games[0].Root)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		var err error
+		moves, err = readMoves(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *from != "" {
+		b, toMove, _, err := board.ParsePosition(*from)
+		if err != nil {
+			log.Fatal(err)
+		}
+		start = b
+		// 手番に合わせて手順の色を振り直す
+		for i := range moves {
+			moves[i].Which = toMove
+			toMove = board.Opponent(toMove)
+		}
+	}
+	if start == nil {
+		start = board.New(*size)
+	}
+	notes, err := ai.Review(start, moves)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, a := range notes {
+		fmt.Printf("%3d. %s\n", i+1, a)
+	}
+}
+
+func readFile(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(name)
+}
+
+func isRecordFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".sgf", ".lib", ".psq", ".pos":
+		return true
+	}
+	return false
+}
+
+func readMoves(name string) ([]board.Move, error) {
+	data, err := readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return parseMoves(string(data))
+}
+
+// 一行に一局ずつ書いたファイルか棋譜ファイルを読む
+// 棋譜ファイルの変化はそれぞれ一局として数える
+func readGames(name string, size int) ([][]board.Move, error) {
+	if isRecordFile(name) {
+		games, err := record.ReadFile(name, size)
+		if err != nil {
+			return nil, err
+		}
+		var lines [][]board.Move
+		for _, g := range games {
+			if g.Size != size {
+				log.Printf("%s: skipping %dx%d game", name, g.Size, g.Size)
+				continue
+			}
+			lines = append(lines, record.Lines(g)...)
+		}
+		return lines, nil
+	}
+	data, err := readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var games [][]board.Move
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		moves, err := parseMoves(line)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, moves)
+	}
+	return games, nil
+}
+
+// 空白区切りの座標(h7 g8 ...)を黒から交互に打った手順として読む
+func parseMoves(s string) ([]board.Move, error) {
+	var moves []board.Move
+	which := board.Black
+	for _, f := range strings.Fields(s) {
+		p, err := board.ParsePoint(f)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, board.Move{X: p.X, Y: p.Y, Which: which})
+		which = board.Opponent(which)
+	}
+	return moves, nil
+}
+
+// 問題ファイルの正解手順をソルバーで確かめる
+func verifyPuzzles(files []string) {
+	failed := false
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		puzzles, err := puzzle.Load(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, p := range puzzles {
+			if err := puzzle.Verify(p); err != nil {
+				fmt.Println(err)
+				failed = true
+				continue
+			}
+			fmt.Printf("ok  %s\n", p.Name)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// 棋譜から定跡を作る
+func buildBook(args []string) {
+	fs := flag.NewFlagSet("book build", flag.ExitOnError)
+	size := fs.Int("size", 13, "board size")
+	ply := fs.Int("ply", book.DefaultPly, "number of opening moves to keep")
+	out := fs.String("o", "book.json", "output file")
+	fs.Parse(args)
+
+	bk := book.New(*size)
+	n := 0
+	for _, name := range fs.Args() {
+		games, err := readGames(name, *size)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, g := range games {
+			if err := book.AddGame(bk, g, *ply); err != nil {
+				log.Printf("%s: %v", name, err)
+				continue
+			}
+			n++
+		}
+	}
+	if err := book.Save(bk, *out); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d games, %d positions\n", n, len(bk.Entries))
+}
+
+func mergeBooks(args []string) {
+	fs := flag.NewFlagSet("book merge", flag.ExitOnError)
+	out := fs.String("o", "book.json", "output file")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	var merged *book.Book
+	for _, name := range fs.Args() {
+		// book.Loadはないファイルを空の定跡として読むので、先に確かめる
+		if _, err := os.Stat(name); err != nil {
+			log.Fatal(err)
+		}
+		bk, err := book.Load(name, 0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if merged == nil {
+			merged = bk
+			continue
+		}
+		merged, err = book.Merge(merged, bk)
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+	}
+	if err := book.Save(merged, *out); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d positions\n", len(merged.Entries))
+}
+
+// 棋譜の形式を変換する
+func convert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	size := fs.Int("size", 15, "board size for .pos files")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		usage()
+	}
+
+	games, err := record.ReadFile(fs.Arg(0), *size)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(games) != 1 {
+		log.Fatalf("%s: has %d games, want 1", fs.Arg(0), len(games))
+	}
+	if err := record.WriteFile(fs.Arg(1), games[0]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// アプリが終局した対局を入れているアーカイブ
+func defaultArchiveDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "gomoku", "archive")
+}
+
+func archiveFlags(name string) (*flag.FlagSet, *string, *archive.Query) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	dir := fs.String("dir", defaultArchiveDir(), "archive directory")
+	q := &archive.Query{}
+	fs.IntVar(&q.Size, "size", 0, "board size")
+	fs.StringVar(&q.Rule, "rule", "", "rule")
+	fs.StringVar(&q.Player, "player", "", "black or white player")
+	fs.StringVar(&q.Result, "result", "", "result (B+, W+, Draw)")
+	return fs, dir, q
+}
+
+func listArchive(args []string) {
+	fs, dir, q := archiveFlags("archive list")
+	fs.Parse(args)
+
+	a, err := archive.Open(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range a.List(*q) {
+		fmt.Printf("%s  %s  %dx%d %-9s %3d手 %-4s %s - %s\n",
+			e.ID, e.Date.Format("2006-01-02 15:04"), e.Size, e.Size, e.Rule,
+			e.Moves, e.Result, e.Black, e.White)
+	}
+}
+
+func exportArchive(args []string) {
+	fs, dir, q := archiveFlags("archive export")
+	out := fs.String("o", "", "output SGF file")
+	fs.Parse(args)
+	if *out == "" {
+		usage()
+	}
+
+	a, err := archive.Open(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	n, err := a.Export(f, *q)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d games\n", n)
+}
+
+// 局面か局所的な形が現れた棋譜と手数を出力する
+// 形は行を/で区切り、xが黒、oが白、.が空点、-がどちらでもよいマス
+func searchArchive(args []string) {
+	fs, dir, q := archiveFlags("archive search")
+	position := fs.String("position", "", "position in board.ParsePosition notation")
+	pattern := fs.String("pattern", "", "local pattern such as \"-x-/.x./-x-\"")
+	fs.Parse(args)
+	if (*position == "") == (*pattern == "") {
+		usage()
+	}
+
+	a, err := archive.Open(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var matches []archive.Match
+	if *position != "" {
+		b, _, _, perr := board.ParsePosition(*position)
+		if perr != nil {
+			log.Fatal(perr)
+		}
+		matches, err = a.SearchPosition(*q, b)
+	} else {
+		p, perr := board.ParsePattern(*pattern)
+		if perr != nil {
+			log.Fatal(perr)
+		}
+		matches, err = a.SearchPattern(*q, p)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, m := range matches {
+		fmt.Printf("%s  %d手目  %s - %s %s\n", m.Entry.ID, m.Move, m.Entry.Black, m.Entry.White, m.Entry.Result)
+	}
+}
+
+// アーカイブの棋譜をレーティングに反映して一覧を出力する
+// -oを指定すれば前回の続きから反映して保存する
+func ratings(args []string) {
+	fs := flag.NewFlagSet("rating", flag.ExitOnError)
+	dir := fs.String("dir", defaultArchiveDir(), "archive directory")
+	out := fs.String("o", "", "ratings file")
+	fs.Parse(args)
+
+	a, err := archive.Open(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := rating.NewRegistry()
+	if *out != "" {
+		if r, err = rating.LoadRegistry(*out); err != nil {
+			log.Fatal(err)
+		}
+	}
+	r.Update(a)
+	for i, p := range r.Ranking() {
+		fmt.Printf("%2d. %-12s Elo %4.0f  Glicko %4.0f±%3.0f  %3d局  黒 %3.0f%%  白 %3.0f%%  平均 %5.1f手\n",
+			i+1, p.Name, p.Elo, p.Glicko.Rating, p.Glicko.RD*2, p.Games(),
+			p.WinRate(board.Black)*100, p.WinRate(board.White)*100, p.AverageLength())
+		var levels []int
+		for level := range p.VsAI {
+			levels = append(levels, level)
+		}
+		sort.Ints(levels)
+		for _, level := range levels {
+			s := p.VsAI[level]
+			fmt.Printf("      対%s %d勝%d敗%d分\n", rating.AIName(level), s.Wins, s.Losses, s.Draws)
+		}
+	}
+	if *out != "" {
+		if err := r.Save(*out); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// サーバーにつないで通信対局を一手ずつ進める
+func correspondence(args []string) {
+	fs := flag.NewFlagSet("corr", flag.ExitOnError)
+	addr := fs.String("server", "localhost:"+network.DefaultPort, "server address")
+	name := fs.String("name", "", "registered name")
+	size := fs.Int("size", network.DefaultSize, "board size for new games")
+	tokensFile := fs.String("tokens", defaultTokensFile(), "file keeping the tokens of your games")
+	fs.Parse(args)
+	cmd := fs.Args()
+	if *name == "" || len(cmd) == 0 {
+		usage()
+	}
+	// 命令ごとの引数の数(命令を含む)
+	need := map[string]int{"list": 1, "new": 1, "show": 2, "move": 3, "resign": 2}
+	if n, ok := need[cmd[0]]; !ok || len(cmd) != n {
+		usage()
+	}
+
+	c, err := network.Dial(*addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Register(*name, 0); err != nil {
+		log.Fatal(err)
+	}
+	await(c, network.MsgRegister)
+	tokens, err := loadTokens(*tokensFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	token := func(id string) string {
+		t := tokens[id]
+		if t == "" {
+			log.Fatalf("no token for game %s in %s", id, *tokensFile)
+		}
+		return t
+	}
+
+	switch cmd[0] {
+	case "list":
+		if err := c.MyGames(); err != nil {
+			log.Fatal(err)
+		}
+		m := await(c, network.MsgMyGames)
+		for _, g := range m.Games {
+			turn := "相手待ち"
+			if g.Turn == board.Black {
+				turn = "手番 " + g.Black
+			} else if g.Turn == board.White {
+				turn = "手番 " + g.White
+			}
+			if g.Turn != board.Space && g.Turn == colorOf(g, *name) {
+				turn += " (自分)"
+			}
+			deadline := ""
+			if g.Deadline != nil {
+				deadline = "  期限 " + g.Deadline.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%s  %dx%d %-9s %3d手  %s - %s  %s%s\n",
+				g.ID, g.Size, g.Size, g.Rule, g.Moves, g.Black, g.White, turn, deadline)
+		}
+	case "new":
+		if err := c.Correspond("", *size, board.Freestyle); err != nil {
+			log.Fatal(err)
+		}
+		m := await(c, network.MsgSync, network.MsgStart)
+		tokens[m.Game] = m.Token
+		if err := saveTokens(*tokensFile, tokens); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(m.Game)
+	case "show":
+		fetchGame(c, cmd[1], token(cmd[1]))
+		printGame(c.Game)
+	case "move":
+		p, err := board.ParsePoint(cmd[2])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fetchGame(c, cmd[1], token(cmd[1]))
+		if err := c.Post(cmd[1], c.Token, p.X, p.Y); err != nil {
+			log.Fatal(err)
+		}
+		await(c, network.MsgPost)
+		printGame(c.Game)
+	case "resign":
+		if err := c.ResignCorrespondence(cmd[1], token(cmd[1])); err != nil {
+			log.Fatal(err)
+		}
+		m := await(c, network.MsgResult)
+		fmt.Println(m.Result)
+	}
+}
+
+// エンジンをボットとしてサーバーにつなぎ、切れるまで打たせる
+// -size 0なら対局を作らず、申し込まれるのを待つだけにする
+func runBot(args []string) {
+	fs := flag.NewFlagSet("bot", flag.ExitOnError)
+	addr := fs.String("server", "localhost:"+network.DefaultPort, "server address")
+	level := fs.Int("level", 0, "search depth (0 for the default engine with book)")
+	size := fs.Int("size", network.DefaultSize, "board size of games to open (0 to wait for challenges)")
+	rule := fs.String("rule", "freestyle", "rule of games to open")
+	tc := fs.String("time", "", "time control of games to open")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+	r, err := board.ParseRule(*rule)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c, err := network.Dial(*addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	c.Time = *tc
+	b := network.NewBot(c, ai.Searcher{Depth: *level})
+	b.Size, b.Rule = *size, r
+	log.Fatal(b.Run(fs.Arg(0)))
+}
+
+func colorOf(g network.GameInfo, name string) int {
+	switch name {
+	case g.Black:
+		return board.Black
+	case g.White:
+		return board.White
+	}
+	return board.Space
+}
+
+// 通信対局の合言葉を対局のIDごとに入れておくファイル
+func defaultTokensFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "gomoku", "corr-tokens.json")
+}
+
+func loadTokens(name string) (map[string]string, error) {
+	tokens := map[string]string{}
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, json.Unmarshal(data, &tokens)
+}
+
+func saveTokens(name string, tokens map[string]string) error {
+	data, err := json.MarshalIndent(tokens, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0600)
+}
+
+func fetchGame(c *network.Client, id string, token string) {
+	if err := c.Fetch(id, token); err != nil {
+		log.Fatal(err)
+	}
+	await(c, network.MsgSync)
+}
+
+// typesのどれかが届くまで読み飛ばす。エラーが届いたら終わる
+func await(c *network.Client, types ...string) network.Message {
+	for {
+		m, err := c.Next()
+		if err == network.ErrResync {
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if m.Type == network.MsgError {
+			log.Fatal(m.Error)
+		}
+		for _, t := range types {
+			if m.Type == t {
+				return m
+			}
+		}
+	}
+}
+
+func printGame(g *game.Game) {
+	fmt.Print("   ")
+	for x := 0; x < g.Size; x++ {
+		fmt.Printf("%c ", 'a'+x)
+	}
+	fmt.Println()
+	for y := 0; y < g.Size; y++ {
+		fmt.Printf("%2d ", y+1)
+		for x := 0; x < g.Size; x++ {
+			switch board.At(g.Board, x, y) {
+			case board.Black:
+				fmt.Print("x ")
+			case board.White:
+				fmt.Print("o ")
+			default:
+				fmt.Print(". ")
+			}
+		}
+		fmt.Println()
+	}
+	if g.Over() {
+		fmt.Println("結果", g.Result)
+	} else if g.Turn == board.Black {
+		fmt.Println("黒番")
+	} else {
+		fmt.Println("白番")
+	}
+}
+
+// 大会を作る。対局者はシード順に並べる(エンジンはAI:深さ)
+func newTournament(args []string) {
+	fs := flag.NewFlagSet("tournament new", flag.ExitOnError)
+	format := fs.String("format", "roundrobin", "roundrobin, swiss or knockout")
+	rounds := fs.Int("rounds", 0, "number of Swiss rounds (0 for log2 of players)")
+	out := fs.String("o", "", "tournament file")
+	fs.Parse(args)
+	if *out == "" || fs.NArg() < 3 {
+		usage()
+	}
+	f, err := tournament.ParseFormat(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t, err := tournament.New(fs.Arg(0), f, fs.Args()[1:], *rounds)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := t.Save(*out); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// pairで次の回戦を組み、playでエンジンどうしの対局を打ち、resultで人の対局の結果を入れる
+func runTournament(cmd string, args []string) {
+	fs := flag.NewFlagSet("tournament "+cmd, flag.ExitOnError)
+	size := fs.Int("size", 15, "board size for engine games")
+	fs.Parse(args)
+	if fs.NArg() < 1 || (cmd == "result") != (fs.NArg() == 4) {
+		usage()
+	}
+	path := fs.Arg(0)
+	t, err := tournament.Load(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch cmd {
+	case "pair":
+		round, err := t.NextRound()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("第%d回戦\n", len(t.Rounds))
+		for _, p := range round {
+			if p.Bye() {
+				fmt.Printf("  %s 不戦勝\n", p.Black)
+			} else {
+				fmt.Printf("  %s - %s\n", p.Black, p.White)
+			}
+		}
+	case "play":
+		games, err := t.PlayEngines(*size, board.Freestyle)
+		for _, g := range games {
+			fmt.Printf("  %3d手 %s\n", len(g.Moves), g.Result)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "result":
+		if err := t.Record(fs.Arg(1), fs.Arg(2), fs.Arg(3)); err != nil {
+			log.Fatal(err)
+		}
+	case "table":
+		if err := t.WriteCrosstable(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := t.Save(path); err != nil {
+		log.Fatal(err)
+	}
+}
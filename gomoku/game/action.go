@@ -0,0 +1,173 @@
+package game
+
+import (
+	"errors"
+
+	"../board"
+	"../record"
+)
+
+var (
+	ErrNoOffer   = errors.New("game: no offer to answer")
+	ErrNotFull   = errors.New("game: five can still be made")
+	ErrTooLate   = errors.New("game: too late")
+	ErrBadPlayer = errors.New("game: bad player")
+)
+
+// この手数より前なら対局をなかったことにできる
+const AbortMoves = 2
+
+// 相手の返事を待つ提案
+type OfferKind int
+
+const (
+	NoOffer   OfferKind = iota
+	DrawOffer           // 引き分け
+	SwapOffer           // 打ち始める前に黒と白を入れ替える
+)
+
+type Offer struct {
+	Kind OfferKind
+	By   int // 提案した側
+}
+
+// whichが投了する
+func (g *Game) Resign(which int) error {
+	if err := g.check(which); err != nil {
+		return err
+	}
+	g.end(Loss(which, ByResign), EndResign)
+	return nil
+}
+
+// whichが放棄したことにして負けにする
+func (g *Game) Forfeit(which int) error {
+	if err := g.check(which); err != nil {
+		return err
+	}
+	g.end(Loss(which, ByForfeit), EndForfeit)
+	return nil
+}
+
+// 引き分けを提案する。相手が打つか断るまで有効
+func (g *Game) OfferDraw(which int) error {
+	if err := g.check(which); err != nil {
+		return err
+	}
+	g.Offer = Offer{Kind: DrawOffer, By: which}
+	return nil
+}
+
+// 黒と白の入れ替えを提案する。まだ一手も打っていないときだけ
+// 受けられたら、誰がどちらを持つかは呼び出した側で入れ替える
+func (g *Game) OfferSwap(which int) error {
+	if err := g.check(which); err != nil {
+		return err
+	}
+	if len(g.Moves) > 0 {
+		return ErrTooLate
+	}
+	g.Offer = Offer{Kind: SwapOffer, By: which}
+	return nil
+}
+
+// 相手の提案を受け、受けた提案を返す
+func (g *Game) Accept(which int) (Offer, error) {
+	o, err := g.answer(which)
+	if err != nil {
+		return o, err
+	}
+	switch o.Kind {
+	case DrawOffer:
+		g.end(record.Draw, EndAgreed)
+	case SwapOffer:
+		if len(g.Moves) > 0 {
+			return o, ErrTooLate
+		}
+	}
+	return o, nil
+}
+
+// 相手の提案を断り、断った提案を返す
+func (g *Game) Decline(which int) (Offer, error) {
+	return g.answer(which)
+}
+
+func (g *Game) answer(which int) (Offer, error) {
+	if err := g.check(which); err != nil {
+		return Offer{}, err
+	}
+	o := g.Offer
+	if o.Kind == NoOffer || o.By != board.Opponent(which) {
+		return o, ErrNoOffer
+	}
+	g.Offer = Offer{}
+	return o, nil
+}
+
+// どちらも五を作れる所がなくなっていれば引き分けにする
+func (g *Game) ClaimDraw(which int) error {
+	if err := g.check(which); err != nil {
+		return err
+	}
+	if canFive(g.Board, board.Black) || canFive(g.Board, board.White) {
+		return ErrNotFull
+	}
+	g.end(record.Draw, EndClaimed)
+	return nil
+}
+
+// 打ち始めてすぐなら、勝ち負けなしで対局をやめる
+func (g *Game) Abort(which int) error {
+	if err := g.check(which); err != nil {
+		return err
+	}
+	if len(g.Moves) >= AbortMoves {
+		return ErrTooLate
+	}
+	g.end(record.Void, EndAborted)
+	return nil
+}
+
+func (g *Game) check(which int) error {
+	if g.Over() {
+		return ErrGameOver
+	}
+	if which != board.Black && which != board.White {
+		return ErrBadPlayer
+	}
+	return nil
+}
+
+func (g *Game) end(result string, e Ending) {
+	g.Result = result
+	g.Ending = e
+	g.Offer = Offer{}
+	g.stopClock()
+}
+
+// whichの石と空点だけの五つ並びが残っているか
+func canFive(b *board.Board, which int) bool {
+	dirs := [][2]int{{1, 0}, {0, 1}, {1, 1}, {1, -1}}
+	n := board.Size(b)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			for _, d := range dirs {
+				if openFive(b, x, y, d[0], d[1], which) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func openFive(b *board.Board, x int, y int, dx int, dy int, which int) bool {
+	for i := 0; i < 5; i++ {
+		// 盤外は-1なので、ここで外れる
+		if w := board.At(b, x+dx*i, y+dy*i); w != board.Space && w != which {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,266 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"../board"
+)
+
+var ErrTimeUp = errors.New("game: time is up")
+
+// 持ち時間の種類
+type TimeKind int
+
+const (
+	SuddenDeath TimeKind = iota // 持ち時間だけ
+	Fischer                     // 一手ごとに加算
+	Byoyomi                     // 持ち時間のあと、一手ごとに決まった時間の秒読みを回数分
+	Canadian                    // 持ち時間のあと、決まった時間内に決まった手数を打つ
+)
+
+type TimeControl struct {
+	Kind      TimeKind
+	Main      time.Duration
+	Increment time.Duration // Fischer
+	Period    time.Duration // Byoyomi, Canadian
+	Periods   int           // Byoyomi の回数
+	Stones    int           // Canadian で Period 内に打つ手数
+}
+
+// 書き方
+//
+//	10m        持ち時間だけ
+//	5m+3s      フィッシャー
+//	10m/30s*5  秒読み30秒を5回
+//	10m/5m:25  5分で25手
+func ParseTimeControl(s string) (TimeControl, error) {
+	bad := fmt.Errorf("game: bad time control %q", s)
+	var tc TimeControl
+	main, rest := s, ""
+	if i := strings.IndexAny(s, "+/"); i >= 0 {
+		main, rest = s[:i], s[i:]
+	}
+	d, err := time.ParseDuration(main)
+	if err != nil || d < 0 {
+		return tc, bad
+	}
+	tc.Main = d
+	switch {
+	case rest == "":
+		tc.Kind = SuddenDeath
+	case rest[0] == '+':
+		tc.Kind = Fischer
+		if tc.Increment, err = time.ParseDuration(rest[1:]); err != nil || tc.Increment < 0 {
+			return tc, bad
+		}
+	case strings.Contains(rest, "*"):
+		tc.Kind = Byoyomi
+		f := strings.SplitN(rest[1:], "*", 2)
+		tc.Period, err = time.ParseDuration(f[0])
+		if err != nil || tc.Period <= 0 {
+			return tc, bad
+		}
+		if tc.Periods, err = strconv.Atoi(f[1]); err != nil || tc.Periods <= 0 {
+			return tc, bad
+		}
+	case strings.Contains(rest, ":"):
+		tc.Kind = Canadian
+		f := strings.SplitN(rest[1:], ":", 2)
+		tc.Period, err = time.ParseDuration(f[0])
+		if err != nil || tc.Period <= 0 {
+			return tc, bad
+		}
+		if tc.Stones, err = strconv.Atoi(f[1]); err != nil || tc.Stones <= 0 {
+			return tc, bad
+		}
+	default:
+		return tc, bad
+	}
+	return tc, nil
+}
+
+func (tc TimeControl) String() string {
+	switch tc.Kind {
+	case Fischer:
+		return fmt.Sprintf("%v+%v", tc.Main, tc.Increment)
+	case Byoyomi:
+		return fmt.Sprintf("%v/%v*%d", tc.Main, tc.Period, tc.Periods)
+	case Canadian:
+		return fmt.Sprintf("%v/%v:%d", tc.Main, tc.Period, tc.Stones)
+	}
+	return tc.Main.String()
+}
+
+// 片方の残り時間
+type PlayerTime struct {
+	Main     time.Duration `json:"main"`
+	Period   time.Duration `json:"period,omitempty"`  // 今の秒読みの残り
+	Periods  int           `json:"periods,omitempty"` // 残りの秒読みの回数(Byoyomi)
+	Stones   int           `json:"stones,omitempty"`  // 今の秒読みで打つ残りの手数(Canadian)
+	Overtime bool          `json:"overtime,omitempty"`
+}
+
+// 対局時計
+// 動いているのはTurnの側の時計で、Punchで相手の時計に切り替わる
+type Clock struct {
+	Control TimeControl
+	Times   [2]PlayerTime // 黒、白の順
+	Turn    int           // 時計を進める側(始まる前はSpace)
+	Running bool
+	Flagged int // 時間切れになった側
+
+	since time.Time
+	Now   func() time.Time // 空ならtime.Now
+}
+
+func NewClock(tc TimeControl) *Clock {
+	pt := PlayerTime{Main: tc.Main}
+	switch tc.Kind {
+	case Byoyomi:
+		pt.Period, pt.Periods = tc.Period, tc.Periods
+	case Canadian:
+		pt.Period, pt.Stones = tc.Period, tc.Stones
+	}
+	// 秒読みの時間は持ち時間を使い切ってから減る
+	return &Clock{Control: tc, Times: [2]PlayerTime{pt, pt}}
+}
+
+func (c *Clock) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// whichの側の時計を動かし始める
+func (c *Clock) Start(which int) {
+	c.Turn = which
+	c.Running = true
+	c.since = c.now()
+}
+
+// 止めるまでの時間は手番の側から引く
+func (c *Clock) Pause() {
+	if !c.Running {
+		return
+	}
+	c.charge(c.now())
+	c.Running = false
+}
+
+func (c *Clock) Resume() {
+	if c.Running || c.Turn == board.Space || c.Flagged != board.Space {
+		return
+	}
+	c.Running = true
+	c.since = c.now()
+}
+
+// 手番の側が打ったので相手の時計に切り替える
+func (c *Clock) Punch() error {
+	if c.Flagged != board.Space {
+		return ErrTimeUp
+	}
+	if c.Running && !c.charge(c.now()) {
+		return ErrTimeUp
+	}
+	pt := &c.Times[c.Turn-1]
+	switch c.Control.Kind {
+	case Fischer:
+		pt.Main += c.Control.Increment
+	case Byoyomi:
+		if pt.Overtime {
+			pt.Period = c.Control.Period
+		}
+	case Canadian:
+		if pt.Overtime {
+			pt.Stones--
+			if pt.Stones == 0 {
+				pt.Stones = c.Control.Stones
+				pt.Period = c.Control.Period
+			}
+		}
+	}
+	c.Turn = board.Opponent(c.Turn)
+	return nil
+}
+
+// 経過した時間を手番の側から引き、時間切れならfalse
+func (c *Clock) charge(now time.Time) bool {
+	d := now.Sub(c.since)
+	c.since = now
+	if !use(&c.Times[c.Turn-1], c.Control, d) {
+		c.Flagged = c.Turn
+		c.Running = false
+		return false
+	}
+	return true
+}
+
+func use(pt *PlayerTime, tc TimeControl, d time.Duration) bool {
+	if !pt.Overtime {
+		if d < pt.Main {
+			pt.Main -= d
+			return true
+		}
+		d -= pt.Main
+		pt.Main = 0
+		if tc.Kind == SuddenDeath || tc.Kind == Fischer {
+			return false
+		}
+		pt.Overtime = true
+	}
+	switch tc.Kind {
+	case Byoyomi:
+		// 使い切った秒読みは一回ずつ減る
+		for d >= pt.Period {
+			d -= pt.Period
+			pt.Periods--
+			if pt.Periods == 0 {
+				pt.Period = 0
+				return false
+			}
+			pt.Period = tc.Period
+		}
+		pt.Period -= d
+	case Canadian:
+		if d >= pt.Period {
+			pt.Period = 0
+			return false
+		}
+		pt.Period -= d
+	}
+	return true
+}
+
+// 今の残り時間(動いている時計は今までの分を引いたもの)
+func (c *Clock) Remaining(which int) PlayerTime {
+	pt := c.Times[which-1]
+	if c.Running && which == c.Turn {
+		use(&pt, c.Control, c.now().Sub(c.since))
+	}
+	return pt
+}
+
+// 時間切れまでの時間
+// 秒読みのPeriodは今の回の残りなので、まだ始まっていない回は決まった長さで数える
+func (c *Clock) Left(which int) time.Duration {
+	pt := c.Remaining(which)
+	left := pt.Main + pt.Period
+	if c.Control.Kind == Byoyomi && pt.Periods > 1 {
+		left += time.Duration(pt.Periods-1) * c.Control.Period
+	}
+	return left
+}
+
+// 時間が切れていればその側を返す
+func (c *Clock) Expired() int {
+	if c.Flagged == board.Space && c.Running && c.Left(c.Turn) <= 0 {
+		c.charge(c.now())
+	}
+	return c.Flagged
+}
@@ -0,0 +1,44 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"../board"
+)
+
+func TestClockLeftByoyomi(t *testing.T) {
+	tc, err := ParseTimeControl("1m/30s*3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	c := NewClock(tc)
+	c.Now = func() time.Time { return now }
+	c.Start(board.Black)
+
+	if left := c.Left(board.Black); left != 150*time.Second {
+		t.Fatalf("left at start = %v", left)
+	}
+	// 持ち時間を使い切り、一回目の秒読みを10秒使ったところ
+	now = now.Add(70 * time.Second)
+	if left := c.Left(board.Black); left != 80*time.Second {
+		t.Fatalf("left in period = %v, want 80s", left)
+	}
+	// 一回目を使い切ると残りは二回分
+	now = now.Add(20 * time.Second)
+	if left := c.Left(board.Black); left != 60*time.Second {
+		t.Fatalf("left after period = %v, want 60s", left)
+	}
+	// 打てば秒読みは戻るが、回数は戻らない
+	now = now.Add(5 * time.Second)
+	if err := c.Punch(); err != nil {
+		t.Fatal(err)
+	}
+	if left := c.Left(board.Black); left != 60*time.Second {
+		t.Fatalf("left after move = %v, want 60s", left)
+	}
+	if left := c.Left(board.White); left != 150*time.Second {
+		t.Fatalf("white left = %v", left)
+	}
+}
@@ -0,0 +1,150 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+
+	"../board"
+	"../record"
+)
+
+var (
+	ErrGameOver    = errors.New("game: game is over")
+	ErrNotPlayable = errors.New("game: point is not playable")
+)
+
+// 対局の状態
+// 盤はStartから手順を並べたもの
+type Game struct {
+	Size   int
+	Rule   board.Rule
+	Start  *board.Board
+	Board  *board.Board
+	Moves  []board.Move
+	Turn   int
+	Result string // 対局中は空
+	Ending Ending
+	Offer  Offer  // 相手の返事を待っている提案
+	Clock  *Clock // 持ち時間がなければnil
+}
+
+func New(size int) *Game {
+	return FromPosition(board.New(size), board.Black)
+}
+
+func FromPosition(b *board.Board, toMove int) *Game {
+	return &Game{
+		Size:  board.Size(b),
+		Start: board.Copy(b),
+		Board: board.Copy(b),
+		Turn:  toMove,
+	}
+}
+
+func (g *Game) Over() bool {
+	return g.Result != ""
+}
+
+// 手番の側が(x, y)に打つ
+func (g *Game) Play(x int, y int) error {
+	if g.Over() {
+		return ErrGameOver
+	}
+	if board.At(g.Board, x, y) != board.Space {
+		return ErrNotPlayable
+	}
+	if g.Clock != nil && g.Clock.Turn != board.Space {
+		if err := g.Clock.Punch(); err != nil {
+			g.Result = TimeLoss(g.Clock.Flagged)
+			g.Ending = EndTime
+			return err
+		}
+	}
+	board.Set(g.Board, x, y, g.Turn)
+	g.Moves = append(g.Moves, board.Move{X: x, Y: y, Which: g.Turn})
+	g.Offer = Offer{}
+	if board.FiveAt(g.Board, x, y) {
+		if g.Turn == board.Black {
+			g.Result = record.BlackWin
+		} else {
+			g.Result = record.WhiteWin
+		}
+		g.Ending = EndFive
+	} else if full(g.Board) {
+		g.Result = record.Draw
+		g.Ending = EndFull
+	}
+	if g.Over() {
+		g.stopClock()
+		return nil
+	}
+	g.Turn = board.Opponent(g.Turn)
+	return nil
+}
+
+// 手番の側の時計を動かし始める
+func (g *Game) StartClock() {
+	if g.Clock != nil && !g.Over() {
+		g.Clock.Start(g.Turn)
+	}
+}
+
+func (g *Game) stopClock() {
+	if g.Clock != nil {
+		g.Clock.Pause()
+	}
+}
+
+// 手番の側の時間が切れていれば負けにしてtrueを返す
+func (g *Game) CheckTime() bool {
+	if g.Clock == nil || g.Over() {
+		return false
+	}
+	if loser := g.Clock.Expired(); loser != board.Space {
+		g.Result = TimeLoss(loser)
+		g.Ending = EndTime
+		return true
+	}
+	return false
+}
+
+func full(b *board.Board) bool {
+	for y := 0; y < board.Size(b); y++ {
+		for x := 0; x < board.Size(b); x++ {
+			if board.At(b, x, y) == board.Space {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// 手順を最初から並べ直して状態を作る
+func Replay(start *board.Board, toMove int, moves []board.Move) (*Game, error) {
+	g := FromPosition(start, toMove)
+	for i, m := range moves {
+		if m.Which != g.Turn {
+			return nil, fmt.Errorf("game: move %d is out of turn", i+1)
+		}
+		if err := g.Play(m.X, m.Y); err != nil {
+			return nil, fmt.Errorf("game: move %d: %v", i+1, err)
+		}
+	}
+	return g, nil
+}
+
+// 棋譜にする
+func (g *Game) Record() *record.Game {
+	r := record.FromMoves(g.Size, g.Moves)
+	r.Rule = g.Rule
+	r.Result = g.Result
+	r.Comment = g.endingComment()
+	for y := 0; y < g.Size; y++ {
+		for x := 0; x < g.Size; x++ {
+			if w := board.At(g.Start, x, y); w != board.Space {
+				r.Root.Setup = append(r.Root.Setup, board.Move{X: x, Y: y, Which: w})
+			}
+		}
+	}
+	return r
+}
@@ -0,0 +1,115 @@
+package game
+
+import (
+	"strings"
+
+	"../board"
+	"../record"
+)
+
+// 終局の仕方
+type Ending int
+
+const (
+	NotEnded   Ending = iota
+	EndFive           // 五が並んだ
+	EndFull           // 盤が埋まった
+	EndResign         // 投了
+	EndAgreed         // 引き分けの提案を受けた
+	EndClaimed        // 五を作れる所がなくなって引き分けを申し出た
+	EndTime           // 時間切れ
+	EndForfeit        // 放棄(切れたまま戻らないなど)
+	EndAborted        // 打ち始めてすぐにやめた
+)
+
+var endingNames = map[Ending]string{
+	EndFive:    "five in a row",
+	EndFull:    "board full",
+	EndResign:  "resignation",
+	EndAgreed:  "draw agreed",
+	EndClaimed: "draw claimed",
+	EndTime:    "time",
+	EndForfeit: "forfeit",
+	EndAborted: "aborted",
+}
+
+func (e Ending) String() string {
+	if s, ok := endingNames[e]; ok {
+		return s
+	}
+	return "not ended"
+}
+
+// Ending.Stringの表記から戻す。わからなければresultから決める
+func ParseEnding(s string, result string) Ending {
+	for e, name := range endingNames {
+		if name == s {
+			return e
+		}
+	}
+	return EndingOf(result)
+}
+
+// SGFと同じく、勝ちのあとに投了はR、時間切れはT、反則や放棄はFをつける
+const (
+	ByResign  = "R"
+	ByTime    = "T"
+	ByForfeit = "F"
+)
+
+// loserが負けたときの結果
+func Loss(loser int, by string) string {
+	if loser == board.Black {
+		return record.WhiteWin + by
+	}
+	return record.BlackWin + by
+}
+
+func TimeLoss(loser int) string {
+	return Loss(loser, ByTime)
+}
+
+// 結果から勝った側を返す(引き分けや無効ならSpace)
+func Winner(result string) int {
+	switch {
+	case strings.HasPrefix(result, record.BlackWin):
+		return board.Black
+	case strings.HasPrefix(result, record.WhiteWin):
+		return board.White
+	}
+	return board.Space
+}
+
+// 結果だけからわかる終局の仕方(引き分けは盤が埋まったものとする)
+func EndingOf(result string) Ending {
+	switch {
+	case result == "":
+		return NotEnded
+	case result == record.Draw:
+		return EndFull
+	case result == record.Void:
+		return EndAborted
+	case strings.HasSuffix(result, ByResign):
+		return EndResign
+	case strings.HasSuffix(result, ByTime):
+		return EndTime
+	case strings.HasSuffix(result, ByForfeit):
+		return EndForfeit
+	}
+	return EndFive
+}
+
+// 五を並べて勝ったのでも盤が埋まったのでもなければ、棋譜に残す説明
+func (g *Game) endingComment() string {
+	switch g.Ending {
+	case NotEnded, EndFive, EndFull:
+		return ""
+	case EndAgreed, EndClaimed, EndAborted:
+		return "Game ended: " + g.Ending.String()
+	}
+	who := "Black"
+	if Winner(g.Result) == board.Black {
+		who = "White"
+	}
+	return who + " lost by " + g.Ending.String()
+}
@@ -0,0 +1,133 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"../board"
+)
+
+// 保存形式を変えたら上げる
+// 1から2で持ち時間を足した(1もそのまま読める)
+const StateVersion = 2
+
+// アプリを離れるときに保存する状態
+type State struct {
+	Game *Game
+	Mode string // "game", "puzzle", "replay"
+	Note string // 詰め五目の問題名など
+	// 詰め五目で受け方に逃げられた(五はできていないが終わっている)
+	Failed bool
+}
+
+type savedState struct {
+	Version int         `json:"version"`
+	Start   string      `json:"start"` // board.FormatPositionの表記
+	Moves   []string    `json:"moves"` // 開始局面の手番から交互に打った座標
+	Result  string      `json:"result,omitempty"`
+	Mode    string      `json:"mode"`
+	Note    string      `json:"note,omitempty"`
+	Failed  bool        `json:"failed,omitempty"`
+	Clock   *savedClock `json:"clock,omitempty"`
+}
+
+// 時計は止めた状態で残り時間だけ保存する
+type savedClock struct {
+	Control string        `json:"control"` // TimeControl.Stringの表記
+	Times   [2]PlayerTime `json:"times"`
+	Flagged int           `json:"flagged,omitempty"`
+}
+
+func SaveState(path string, st *State) error {
+	g := st.Game
+	// 最初の手番は手順の最初の手か、今の手番から決まる
+	first := g.Turn
+	if len(g.Moves) > 0 {
+		first = g.Moves[0].Which
+	}
+	moves := make([]string, len(g.Moves))
+	for i, m := range g.Moves {
+		moves[i] = board.FormatPoint(board.Point{X: m.X, Y: m.Y})
+	}
+	saved := savedState{
+		Version: StateVersion,
+		Start:   board.FormatPosition(g.Start, first, g.Rule),
+		Moves:   moves,
+		Result:  g.Result,
+		Mode:    st.Mode,
+		Note:    st.Note,
+		Failed:  st.Failed,
+	}
+	if c := g.Clock; c != nil {
+		saved.Clock = &savedClock{
+			Control: c.Control.String(),
+			Times:   [2]PlayerTime{c.Remaining(board.Black), c.Remaining(board.White)},
+			Flagged: c.Flagged,
+		}
+	}
+	data, err := json.MarshalIndent(saved, "", "\t")
+	if err != nil {
+		return err
+	}
+	// 途中で落ちても壊れないように書いてから置き換える
+	tmp := path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func LoadState(path string) (*State, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s savedState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Version < 1 || s.Version > StateVersion {
+		return nil, fmt.Errorf("game: unsupported state version %d", s.Version)
+	}
+	start, toMove, rule, err := board.ParsePosition(s.Start)
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]board.Move, len(s.Moves))
+	which := toMove
+	for i, v := range s.Moves {
+		p, err := board.ParsePoint(v)
+		if err != nil {
+			return nil, err
+		}
+		moves[i] = board.Move{X: p.X, Y: p.Y, Which: which}
+		which = board.Opponent(which)
+	}
+	g, err := Replay(start, toMove, moves)
+	if err != nil {
+		return nil, err
+	}
+	g.Rule = rule
+	if s.Result != "" {
+		g.Result = s.Result
+		g.Ending = EndingOf(s.Result)
+	}
+	// 時計は止めたまま戻すので、続けるときはStartClockを呼ぶ
+	if s.Clock != nil {
+		tc, err := ParseTimeControl(s.Clock.Control)
+		if err != nil {
+			return nil, err
+		}
+		g.Clock = NewClock(tc)
+		g.Clock.Times = s.Clock.Times
+		g.Clock.Turn = g.Turn
+		g.Clock.Flagged = s.Clock.Flagged
+	}
+	return &State{Game: g, Mode: s.Mode, Note: s.Note, Failed: s.Failed}, nil
+}
@@ -31,12 +31,26 @@ package main
 import (
 	"bytes"
 	"image"
+	"image/color"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	_ "image/png"
 
+	"./ai"
+	"./archive"
 	"./board"
+	"./book"
+	"./game"
+	"./puzzle"
+	"./rating"
+	"./record"
 
 	"golang.org/x/mobile/app"
 	"golang.org/x/mobile/event/lifecycle"
@@ -65,30 +79,99 @@ var (
 	scene     *sprite.Node
 	fps       *debug.FPS
 
-	endFlag   bool
-	goisiTexs []sprite.SubTex
-	loadscene bool
-	whichTurn int
-	b         *board.Board
+	endFlag    bool
+	goisiTexs  []sprite.SubTex
+	loadscene  bool
+	whichTurn  int
+	b          *board.Board
+	startBoard *board.Board // 対局を始めた局面
 
 	prevPosX int
 	prevPosY int
 	prevN    *sprite.Node
+
+	touchBegin time.Time
+
+	// 活三・四の表示
+	showThreats = true
+	markerTexs  []sprite.SubTex
+	threatNodes []*sprite.Node
+
+	// 形勢バー
+	evalCh       = make(chan evalRequest, 1)
+	evalMu       sync.Mutex
+	evalGen      int // 依頼するたびに増やし、古い依頼の結果を捨てる
+	blackWinRate = 0.5
+	evalTexs     []sprite.SubTex
+	evalBlackN   *sprite.Node
+	evalWhiteN   *sprite.Node
+
+	// 棋譜と検討モード
+	moves       []board.Move
+	stoneNodes  []*sprite.Node
+	reviewing   bool
+	reviewStep  int
+	reviewMu    sync.Mutex
+	reviewNotes []ai.Annotation
+	reviewNodes []*sprite.Node
+
+	// 詰め五目
+	mode     int
+	puzzles  []*puzzle.Puzzle
+	progress *puzzle.Progress
+	curPuz   *puzzle.Puzzle
+	replayNo int
+
+	// 定跡手の表示
+	bookNodes []*sprite.Node
+
+	// 終局した対局の保存先
+	gameArchive *archive.Archive
+
+	// 五が並ぶ以外で終わったときの結果(時間切れ、ネットワーク対局の結果)と
+	// 終わり方(game.Ending.Stringの表記。空なら結果から決める)
+	endResult string
+	endText   string
 )
 
+const (
+	modeGame = iota
+	modePuzzle
+	modeReplay // 大会の棋譜などを並べる
+	modeStats  // 対局者の成績
+	modeNetwork
+	modeWatch // ネットワーク対局の観戦
+)
+
+type evalRequest struct {
+	b      *board.Board
+	toMove int
+	gen    int
+}
+
 func main() {
+	go evalLoop()
+	loadBook()
 	app.Main(func(a app.App) {
 		var glctx gl.Context
 		sz := size.Event{}
+		startNetwork(a)
 		for e := range a.Events() {
 			switch e := a.Filter(e).(type) {
 			case lifecycle.Event:
 				switch e.Crosses(lifecycle.StageVisible) {
 				case lifecycle.CrossOn:
 					glctx, _ = e.DrawContext.(gl.Context)
-					onStart(glctx, sz)
+					if netClient != nil && netMode() {
+						resumeNetwork(glctx, sz)
+					} else {
+						onStart(glctx, sz)
+						restoreState(sz)
+					}
 					a.Send(paint.Event{})
 				case lifecycle.CrossOff:
+					// 他のアプリに切り替えても対局を続けられるように保存する
+					saveState()
 					loadscene = false
 					glctx = nil
 					onStop()
@@ -102,12 +185,35 @@ func main() {
 				onPaint(glctx, sz)
 				a.Publish()
 				repaint(a) // keep animating
+			case netEvent:
+				// 裏に回っている間に届いたものは、戻ってきてから並べる
+				if glctx == nil {
+					netPending = append(netPending, e.m)
+					continue
+				}
+				onNetMessage(glctx, sz, e.m)
 			case touch.Event:
+				// 観戦中は盤に触れても何もしない
+				if mode == modeWatch && !endFlag {
+					continue
+				}
 				if endFlag {
-					// 終了していたらタッチで再スタート
-					onStart(glctx, sz)
+					// 終了していたらタッチで検討モードに入る
+					// 詰め五目なら次の問題(失敗したら同じ問題)を出す
+					// 成績画面からは棋譜再生に移る
+					if e.Type == touch.TypeEnd {
+						switch mode {
+						case modePuzzle:
+							startPuzzle(glctx, sz)
+						case modeStats:
+							startReplay(glctx, sz)
+						default:
+							onReviewTouch(glctx, e, sz)
+						}
+					}
+					continue
 				}
-				onTouchEnd(e, sz)
+				onTouchEnd(glctx, e, sz)
 			}
 		}
 	})
@@ -116,11 +222,20 @@ func main() {
 func onStart(glctx gl.Context, sz size.Event) {
 	endFlag = false
 	b = board.New13()
+	startBoard = board.New13()
 	whichTurn = BLACK
+	moves = nil
+	reviewing = false
+	mode = modeGame
+	endResult = ""
+	endText = ""
+	gameClock = newLocalClock()
+	setWinRate(0.5)
 	images = glutil.NewImages(glctx)
 	fps = debug.NewFPS(images)
 	eng = glsprite.Engine(images)
 	loadScene(glctx, sz)
+	updateBookHints(b, sz)
 }
 
 func onStop() {
@@ -133,6 +248,9 @@ func onPaint(glctx gl.Context, sz size.Event) {
 	glctx.ClearColor(1, 1, 1, 1)
 	glctx.Clear(gl.COLOR_BUFFER_BIT)
 	now := clock.Time(time.Since(startTime) * 60 / time.Second)
+	updateEvalBar(sz)
+	checkClock()
+	updateClockBars(sz)
 	eng.Render(scene, now, sz)
 	fps.Draw(sz)
 	// androidでonStart時に
@@ -143,7 +261,7 @@ func onPaint(glctx gl.Context, sz size.Event) {
 	}
 }
 
-func onTouchEnd(e touch.Event, sz size.Event) {
+func onTouchEnd(glctx gl.Context, e touch.Event, sz size.Event) {
 	var (
 		offset = 1
 		posX   int
@@ -151,6 +269,11 @@ func onTouchEnd(e touch.Event, sz size.Event) {
 		n      *sprite.Node
 	)
 
+	if e.Type.String() == "begin" {
+		touchBegin = time.Now()
+	}
+	longPress := e.Type.String() == "end" && time.Since(touchBegin) > time.Second
+
 	//log.Printf("x", touchX/sz.PixelsPerPt)
 	//log.Printf("y", touchY/sz.PixelsPerPt)
 
@@ -170,6 +293,30 @@ func onTouchEnd(e touch.Event, sz size.Event) {
 
 	// 盤外
 	if posX < 0 || posX > 12 || posY < 0 || posY > 12 {
+		// 盤の下をタップしたら活三・四の表示を切り替える
+		// 長押しなら保存しておいた局面を貼り付ける
+		if posY > 12 && longPress {
+			pastePosition(glctx, sz)
+		} else if posY > 12 && e.Type.String() == "end" {
+			showThreats = !showThreats
+			updateThreats(b, sz)
+		}
+		// 盤の上をタップしたら対局、詰め五目、成績、棋譜再生を切り替える
+		// 長押しなら今の局面をコピーする
+		if posY < 0 && longPress {
+			copyPosition()
+		} else if posY < 0 && e.Type.String() == "end" {
+			switch mode {
+			case modeNetwork, modeWatch:
+				// ネットワーク対局中は切り替えない
+			case modeGame:
+				startPuzzle(glctx, sz)
+			case modePuzzle:
+				showStats(glctx, sz)
+			default:
+				newGame(glctx, sz)
+			}
+		}
 		return
 	}
 
@@ -182,19 +329,27 @@ func onTouchEnd(e touch.Event, sz size.Event) {
 		} else {
 			eng.SetSubTex(prevN, goisiTexs[texWhite])
 		}
-		eng.SetTransform(prevN, f32.Affine{
-			{float32(sz.WidthPx/12) / sz.PixelsPerPt, 0, float32(float32(sz.WidthPx/12*posX)/sz.PixelsPerPt - float32(sz.WidthPx/12)/sz.PixelsPerPt/2)},
-			{0, float32(sz.WidthPx/12) / sz.PixelsPerPt, float32(float32(sz.WidthPx/12*posY)/sz.PixelsPerPt-float32(sz.WidthPx/12)/sz.PixelsPerPt/2) + float32((sz.HeightPt-sz.WidthPt)/2)},
-		})
+		eng.SetTransform(prevN, stoneAffine(sz, posX, posY))
 	case "move":
 		// タッチ中は動かせる
-		eng.SetTransform(prevN, f32.Affine{
-			{float32(sz.WidthPx/12) / sz.PixelsPerPt, 0, float32(float32(sz.WidthPx/12*posX)/sz.PixelsPerPt - float32(sz.WidthPx/12)/sz.PixelsPerPt/2)},
-			{0, float32(sz.WidthPx/12) / sz.PixelsPerPt, float32(float32(sz.WidthPx/12*posY)/sz.PixelsPerPt-float32(sz.WidthPx/12)/sz.PixelsPerPt/2) + float32((sz.HeightPt-sz.WidthPt)/2)},
-		})
+		eng.SetTransform(prevN, stoneAffine(sz, posX, posY))
 	case "end":
 		// 話したら石を置く
 		eng.SetSubTex(prevN, sprite.SubTex{})
+		// 盤を長く押し続けたら投了する
+		if time.Since(touchBegin) > resignPress {
+			resign()
+			return
+		}
+		if mode == modeNetwork {
+			sendNetMove(posX, posY)
+			return
+		}
+		// エンジンの手番なら、盤に触れたらエンジンが打つ
+		if _, ok := engineFor(whichTurn); ok {
+			playEngine(sz)
+			return
+		}
 		n = newNode()
 		if whichTurn == BLACK {
 			eng.SetSubTex(n, goisiTexs[texBlack])
@@ -207,25 +362,128 @@ func onTouchEnd(e touch.Event, sz size.Event) {
 		log.Printf("posY", posY)
 
 		// 置けるかどうか
+		if !clockAllows() {
+			return
+		}
 		canPut := board.PutPos(b, posX, posY, whichTurn)
 		if !canPut {
 			return
 		}
 
-		eng.SetTransform(n, f32.Affine{
-			{float32(sz.WidthPx/12) / sz.PixelsPerPt, 0, float32(float32(sz.WidthPx/12*posX)/sz.PixelsPerPt - float32(sz.WidthPx/12)/sz.PixelsPerPt/2)},
-			{0, float32(sz.WidthPx/12) / sz.PixelsPerPt, float32(float32(sz.WidthPx/12*posY)/sz.PixelsPerPt-float32(sz.WidthPx/12)/sz.PixelsPerPt/2) + float32((sz.HeightPt-sz.WidthPt)/2)},
-		})
+		eng.SetTransform(n, stoneAffine(sz, posX, posY))
+		stoneNodes = append(stoneNodes, n)
+		moves = append(moves, board.Move{X: posX, Y: posY, Which: whichTurn})
+		punchClock()
+		updateThreats(b, sz)
+		removeNodes(bookNodes)
+		bookNodes = nil
 
 		// 終了判定
 		gameEnd := board.GameEnd(b)
 		if gameEnd {
 			endFlag = true
+			if gameClock != nil {
+				gameClock.Pause()
+			}
+			if mode == modePuzzle {
+				solvePuzzle()
+			} else {
+				analyzeGame()
+			}
+			return
+		}
+
+		if mode == modePuzzle {
+			// 詰め五目ではコンピュータが受けて、手番は攻め方のまま
+			replyPuzzle(sz)
 			return
 		}
 
 		// ターン交代
 		changeTurn()
+		requestEval(b, whichTurn)
+		updateBookHints(b, sz)
+		playEngine(sz)
+	}
+}
+
+// players.txtでrating.AIName(探索の深さ)の名前にした色はエンジンが打つ
+// 棋譜にもその名前で残るので、レーティングではAIの強さごとの成績になる
+func engineFor(which int) (ai.Engine, bool) {
+	if mode != modeGame {
+		return nil, false
+	}
+	black, white := playerNames()
+	name := black
+	if which == WHITE {
+		name = white
+	}
+	level, ok := rating.AILevel(name)
+	if !ok {
+		return nil, false
+	}
+	return ai.Searcher{Depth: level}, true
+}
+
+// エンジンの手番なら一手打つ
+func playEngine(sz size.Event) {
+	e, ok := engineFor(whichTurn)
+	if endFlag || !ok || !clockAllows() {
+		return
+	}
+	var left time.Duration
+	if gameClock != nil {
+		left = gameClock.Left(whichTurn)
+	}
+	p := e.Move(b, whichTurn, left)
+	// 打てる手がなければ投了する
+	if board.At(b, p.X, p.Y) != SPACE {
+		resign()
+		return
+	}
+	board.PutPos(b, p.X, p.Y, whichTurn)
+	addStone(sz, p.X, p.Y, whichTurn)
+	moves = append(moves, board.Move{X: p.X, Y: p.Y, Which: whichTurn})
+	punchClock()
+	updateThreats(b, sz)
+	removeNodes(bookNodes)
+	bookNodes = nil
+	if board.GameEnd(b) {
+		endFlag = true
+		if gameClock != nil {
+			gameClock.Pause()
+		}
+		analyzeGame()
+		return
+	}
+	changeTurn()
+	requestEval(b, whichTurn)
+	updateBookHints(b, sz)
+}
+
+// 投了するときに押し続ける長さ
+const resignPress = 3 * time.Second
+
+// 手元の対局なら手番の側が投了する
+// ネットワーク対局ではサーバーに伝え、結果が届いたら終わる
+func resign() {
+	switch mode {
+	case modeNetwork:
+		if netClient == nil {
+			return
+		}
+		if err := netClient.Resign(); err != nil {
+			log.Println(err)
+		}
+	case modeGame:
+		if gameClock != nil {
+			gameClock.Pause()
+		}
+		endFlag = true
+		endResult = game.Loss(whichTurn, game.ByResign)
+		endText = game.EndResign.String()
+		log.Println("投了:", endResult)
+		analyzeGame()
 	}
 }
 
@@ -237,6 +495,500 @@ func changeTurn() {
 	}
 }
 
+func stoneAffine(sz size.Event, posX int, posY int) f32.Affine {
+	return f32.Affine{
+		{float32(sz.WidthPx/12) / sz.PixelsPerPt, 0, float32(float32(sz.WidthPx/12*posX)/sz.PixelsPerPt - float32(sz.WidthPx/12)/sz.PixelsPerPt/2)},
+		{0, float32(sz.WidthPx/12) / sz.PixelsPerPt, float32(float32(sz.WidthPx/12*posY)/sz.PixelsPerPt-float32(sz.WidthPx/12)/sz.PixelsPerPt/2) + float32((sz.HeightPt-sz.WidthPt)/2)},
+	}
+}
+
+// 盤上の活三・四に印をつけ直す
+func updateThreats(b *board.Board, sz size.Event) {
+	removeNodes(threatNodes)
+	threatNodes = nil
+	if !showThreats {
+		return
+	}
+	for _, t := range board.Threats(b, 0) {
+		for _, p := range t.Stones {
+			n := newNode()
+			eng.SetSubTex(n, markerTexs[markerIndex(t)])
+			eng.SetTransform(n, stoneAffine(sz, p.X, p.Y))
+			threatNodes = append(threatNodes, n)
+		}
+	}
+}
+
+// 終局後に裏で棋譜を検討しておく
+// 棋譜は終局時にアーカイブに入れ、検討が終わったら注釈をつけて保存し直す
+func analyzeGame() {
+	ms := append([]board.Move(nil), moves...)
+	result := record.Draw
+	if n := len(ms); n > 0 && board.FiveAt(b, ms[n-1].X, ms[n-1].Y) {
+		if ms[n-1].Which == BLACK {
+			result = record.BlackWin
+		} else {
+			result = record.WhiteWin
+		}
+	}
+	if endResult != "" {
+		result = endResult
+	}
+	// 投了や合意の引き分けなどの終わり方も棋譜のコメントに残す
+	ended := &game.Game{
+		Size:   13,
+		Start:  startBoard,
+		Moves:  ms,
+		Result: result,
+		Ending: game.ParseEnding(endText, result),
+	}
+	g := ended.Record()
+	g.Black, g.White = playerNames()
+	if netMode() {
+		g.Black, g.White = netBlack, netWhite
+	}
+	a := openArchive()
+	var id string
+	if a != nil {
+		e, err := a.Add(g, time.Now())
+		if err != nil {
+			log.Println(err)
+		}
+		id = e.ID
+		updateRatings(a)
+	}
+
+	runReview(ms, func(notes []ai.Annotation) {
+		// 悪手にはコメントと、エンジンの推奨手順の変化をつける
+		n := g.Root
+		for _, a := range notes {
+			n = n.Children[0]
+			if !a.Blunder && !a.MissedWin {
+				continue
+			}
+			n.Comment = a.String()
+			line := a.WinLine
+			if len(line) == 0 {
+				line = []board.Point{a.Best}
+			}
+			record.AddVariation(n, line, "engine")
+		}
+		if id == "" {
+			return
+		}
+		if err := a.Update(id, g); err != nil {
+			log.Println(err)
+		}
+	})
+}
+
+func openArchive() *archive.Archive {
+	if gameArchive == nil {
+		a, err := archive.Open(filepath.Join(dataDir(), "archive"))
+		if err != nil {
+			log.Println(err)
+			return nil
+		}
+		gameArchive = a
+	}
+	return gameArchive
+}
+
+func runReview(ms []board.Move, done func([]ai.Annotation)) {
+	start := board.Copy(startBoard)
+	reviewMu.Lock()
+	reviewNotes = nil
+	reviewMu.Unlock()
+	go func() {
+		notes, err := ai.Review(start, ms)
+		if err != nil {
+			log.Println(err)
+		}
+		reviewMu.Lock()
+		reviewNotes = notes
+		reviewMu.Unlock()
+		if done != nil {
+			done(notes)
+		}
+	}()
+}
+
+// replayディレクトリに置いた棋譜を順に検討モードで並べる
+func startReplay(glctx gl.Context, sz size.Event) {
+	dir := filepath.Join(dataDir(), "replay")
+	names, _ := filepath.Glob(filepath.Join(dir, "*"))
+	sort.Strings(names)
+	onStart(glctx, sz)
+	for i := 0; i < len(names); i++ {
+		name := names[(replayNo+i)%len(names)]
+		games, err := record.ReadFile(name, 15)
+		if err != nil || len(games) == 0 {
+			log.Println(name, err)
+			continue
+		}
+		// 13路に収まらない棋譜は飛ばす
+		g, err := record.Fit(games[0], 13)
+		if err != nil {
+			log.Println(name, err)
+			continue
+		}
+		replayNo = (replayNo + i + 1) % len(names)
+		log.Println("棋譜再生:", name)
+
+		start, err := record.Position(g, g.Root)
+		if err != nil {
+			log.Println(name, err)
+			continue
+		}
+		mode = modeReplay
+		startBoard = start
+		moves = record.MainLine(g)
+		endFlag = true
+		reviewing = true
+		reviewStep = 0
+		runReview(moves, nil)
+		showReviewStep(sz)
+		return
+	}
+}
+
+// 検討モードでは盤の左半分で一手戻し、右半分で一手進める
+// 盤の下をタップしたら新しい対局を始める
+func onReviewTouch(glctx gl.Context, e touch.Event, sz size.Event) {
+	if !reviewing {
+		reviewing = true
+		reviewStep = len(moves)
+		showReviewStep(sz)
+		return
+	}
+	if e.Y/sz.PixelsPerPt > float32((sz.HeightPt+sz.WidthPt)/2) {
+		newGame(glctx, sz)
+		return
+	}
+	// 棋譜再生中は盤の上で次の棋譜へ
+	if mode == modeReplay && e.Y/sz.PixelsPerPt < float32((sz.HeightPt-sz.WidthPt)/2) {
+		startReplay(glctx, sz)
+		return
+	}
+	if int(e.X) < sz.WidthPx/2 {
+		if reviewStep > 0 {
+			reviewStep--
+		}
+	} else if reviewStep < len(moves) {
+		reviewStep++
+	}
+	showReviewStep(sz)
+}
+
+func showReviewStep(sz size.Event) {
+	removeNodes(reviewNodes)
+	removeNodes(bookNodes)
+	reviewNodes = nil
+	bookNodes = nil
+
+	rb := board.Copy(startBoard)
+	for _, m := range moves[:reviewStep] {
+		board.Set(rb, m.X, m.Y, m.Which)
+	}
+	drawStones(rb, sz)
+
+	reviewMu.Lock()
+	notes := reviewNotes
+	reviewMu.Unlock()
+	if reviewStep == 0 || reviewStep > len(notes) {
+		return
+	}
+	a := notes[reviewStep-1]
+	log.Printf("%d. %s", reviewStep, a)
+
+	mark := func(p board.Point, tex int) {
+		n := newNode()
+		eng.SetSubTex(n, markerTexs[tex])
+		eng.SetTransform(n, stoneAffine(sz, p.X, p.Y))
+		reviewNodes = append(reviewNodes, n)
+	}
+	played := board.Point{X: a.Move.X, Y: a.Move.Y}
+	if a.MissedWin {
+		mark(played, markMissedWin)
+	} else if a.Blunder {
+		mark(played, markBlunder)
+	}
+	if a.Best != played {
+		mark(a.Best, markBest)
+	}
+}
+
+func addStone(sz size.Event, x int, y int, which int) {
+	n := newNode()
+	if which == BLACK {
+		eng.SetSubTex(n, goisiTexs[texBlack])
+	} else {
+		eng.SetSubTex(n, goisiTexs[texWhite])
+	}
+	eng.SetTransform(n, stoneAffine(sz, x, y))
+	stoneNodes = append(stoneNodes, n)
+}
+
+// 盤面の石を全部置き直す
+func drawStones(b *board.Board, sz size.Event) {
+	removeNodes(stoneNodes)
+	stoneNodes = nil
+	for y := 0; y < board.Size(b); y++ {
+		for x := 0; x < board.Size(b); x++ {
+			if w := board.At(b, x, y); w != SPACE {
+				addStone(sz, x, y, w)
+			}
+		}
+	}
+	updateThreats(b, sz)
+}
+
+// まだ解いていない問題から始める
+func startPuzzle(glctx gl.Context, sz size.Event) {
+	if puzzles == nil {
+		loadPuzzles()
+	}
+	if len(puzzles) == 0 {
+		return
+	}
+	newGame(glctx, sz)
+	mode = modePuzzle
+	curPuz = puzzles[progress.Next(puzzles)]
+	b = board.Copy(curPuz.Board)
+	startBoard = board.Copy(curPuz.Board)
+	whichTurn = curPuz.ToMove
+	drawStones(b, sz)
+	removeNodes(bookNodes)
+	bookNodes = nil
+	requestEval(b, whichTurn)
+	log.Println("詰め五目:", curPuz.Name)
+}
+
+func statePath() string {
+	return filepath.Join(dataDir(), "state.json")
+}
+
+// 新しい対局を始める
+// 前の対局の保存は消して、終わった対局がアプリを開き直したときに戻らないようにする
+func newGame(glctx gl.Context, sz size.Event) {
+	onStart(glctx, sz)
+	if err := os.Remove(statePath()); err != nil && !os.IsNotExist(err) {
+		log.Println(err)
+	}
+}
+
+func saveState() {
+	if b == nil {
+		return
+	}
+	g := &game.Game{
+		Size:  13,
+		Start: startBoard,
+		Board: b,
+		Moves: moves,
+		Turn:  whichTurn,
+	}
+	if mode == modeGame {
+		g.Clock = gameClock
+	}
+	if endResult != "" {
+		g.Result = endResult
+	} else if n := len(moves); endFlag && n > 0 && board.FiveAt(b, moves[n-1].X, moves[n-1].Y) {
+		if moves[n-1].Which == BLACK {
+			g.Result = record.BlackWin
+		} else {
+			g.Result = record.WhiteWin
+		}
+	}
+	st := &game.State{Game: g, Mode: "game"}
+	switch mode {
+	case modePuzzle:
+		st.Mode = "puzzle"
+		st.Note = curPuz.Name
+		st.Failed = endFlag && g.Result == ""
+	case modeReplay:
+		st.Mode = "replay"
+	case modeNetwork, modeWatch:
+		st.Mode = "network"
+	}
+	if err := game.SaveState(statePath(), st); err != nil {
+		log.Println(err)
+	}
+}
+
+func restoreState(sz size.Event) {
+	st, err := game.LoadState(statePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return
+	}
+	if st.Game.Size != 13 {
+		return
+	}
+	switch st.Mode {
+	case "puzzle":
+		if puzzles == nil {
+			loadPuzzles()
+		}
+		curPuz = nil
+		for _, p := range puzzles {
+			if p.Name == st.Note {
+				curPuz = p
+			}
+		}
+		if curPuz == nil {
+			return
+		}
+		mode = modePuzzle
+	case "replay":
+		mode = modeReplay
+	case "network":
+		// ネットワーク対局は続きから始められない
+		return
+	}
+	b = st.Game.Board
+	startBoard = st.Game.Start
+	moves = st.Game.Moves
+	whichTurn = st.Game.Turn
+	endFlag = st.Game.Over() || mode == modePuzzle && st.Failed
+	drawStones(b, sz)
+	requestEval(b, whichTurn)
+	if mode == modeGame {
+		restoreClock(st.Game)
+		if endFlag && !board.GameEnd(b) {
+			endResult = st.Game.Result
+		}
+	} else {
+		gameClock = nil
+	}
+
+	switch {
+	case mode == modeReplay:
+		endFlag = true
+		reviewing = true
+		reviewStep = len(moves)
+		runReview(moves, nil)
+		showReviewStep(sz)
+	case mode == modeGame && endFlag:
+		runReview(moves, nil)
+	case mode == modeGame:
+		updateBookHints(b, sz)
+	}
+}
+
+// クリップボードが使えないので局面表記をファイルに書いて受け渡す
+func copyPosition() {
+	pos := board.FormatPosition(b, whichTurn, board.Freestyle)
+	log.Println("局面:", pos)
+	if err := ioutil.WriteFile(filepath.Join(dataDir(), "position.txt"), []byte(pos+"\n"), 0644); err != nil {
+		log.Println(err)
+	}
+}
+
+// 保存しておいた局面から対局を始める
+func pastePosition(glctx gl.Context, sz size.Event) {
+	data, err := ioutil.ReadFile(filepath.Join(dataDir(), "position.txt"))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	pb, toMove, _, err := board.ParsePosition(string(data))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if board.Size(pb) != 13 {
+		log.Println("13路の局面ではありません")
+		return
+	}
+	newGame(glctx, sz)
+	b = pb
+	startBoard = board.Copy(pb)
+	whichTurn = toMove
+	drawStones(b, sz)
+	requestEval(b, whichTurn)
+	updateBookHints(b, sz)
+}
+
+func loadPuzzles() {
+	var err error
+	progress, err = puzzle.LoadProgress(filepath.Join(dataDir(), "progress.json"))
+	if err != nil {
+		log.Println(err)
+		progress = &puzzle.Progress{Solved: map[string]time.Time{}}
+	}
+	puzzles, err = puzzle.Load(strings.NewReader(builtinPuzzles))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 追加の問題があれば読む
+	if f, err := os.Open(filepath.Join(dataDir(), "puzzles.txt")); err == nil {
+		more, err := puzzle.Load(f)
+		f.Close()
+		if err != nil {
+			log.Println(err)
+		}
+		puzzles = append(puzzles, more...)
+	}
+}
+
+func replyPuzzle(sz size.Event) {
+	attacker := whichTurn
+	defender := board.Opponent(attacker)
+	r, _ := ai.Search(b, defender, 2)
+	if r.X < 0 {
+		return
+	}
+	board.PutPos(b, r.X, r.Y, defender)
+	addStone(sz, r.X, r.Y, defender)
+	moves = append(moves, board.Move{X: r.X, Y: r.Y, Which: defender})
+	updateThreats(b, sz)
+	requestEval(b, attacker)
+
+	if board.FiveAt(b, r.X, r.Y) || !puzzle.StillWinning(b, attacker) {
+		log.Println("失敗です")
+		endFlag = true
+	}
+}
+
+func solvePuzzle() {
+	log.Println("正解です")
+	progress.MarkSolved(curPuz.Name)
+	if err := progress.Save(filepath.Join(dataDir(), "progress.json")); err != nil {
+		log.Println(err)
+	}
+}
+
+func loadBook() {
+	bk, err := book.Load(filepath.Join(dataDir(), "book.json"), 13)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	ai.SetBook(bk)
+}
+
+// 序盤は定跡の候補手に印をつける
+func updateBookHints(b *board.Board, sz size.Event) {
+	removeNodes(bookNodes)
+	bookNodes = nil
+	for _, c := range ai.BookCandidates(b) {
+		n := newNode()
+		eng.SetSubTex(n, markerTexs[markBook])
+		eng.SetTransform(n, stoneAffine(sz, c.Move.X, c.Move.Y))
+		bookNodes = append(bookNodes, n)
+	}
+}
+
+func removeNodes(nodes []*sprite.Node) {
+	for _, n := range nodes {
+		scene.RemoveChild(n)
+		eng.Unregister(n)
+	}
+}
+
 func newNode() *sprite.Node {
 	n := &sprite.Node{}
 	eng.Register(n)
@@ -250,6 +1002,13 @@ func loadScene(glctx gl.Context, sz size.Event) {
 	}
 	texs := loadTextures()
 	goisiTexs = loadGoisiTextures(glctx)
+	markerTexs = loadMarkerTextures()
+	evalTexs = loadEvalTextures()
+	digitTexs = loadDigitTextures()
+	threatNodes = nil
+	stoneNodes = nil
+	reviewNodes = nil
+	bookNodes = nil
 	scene = &sprite.Node{}
 	eng.Register(scene)
 	eng.SetTransform(scene, f32.Affine{
@@ -269,6 +1028,73 @@ func loadScene(glctx gl.Context, sz size.Event) {
 		{0, float32(sz.WidthPt), float32((sz.HeightPt - sz.WidthPt) / 2)},
 		//	{0, float32(sz.WidthPt), 0},
 	})
+
+	evalBlackN = newNode()
+	eng.SetSubTex(evalBlackN, evalTexs[texEvalBlack])
+	evalWhiteN = newNode()
+	eng.SetSubTex(evalWhiteN, evalTexs[texEvalWhite])
+	loadClockNodes()
+
+	// 作り直した場面に今の盤を並べ直す
+	if b != nil {
+		drawStones(b, sz)
+	}
+}
+
+// 形勢判断は重いので別のgoroutineで計算する
+func evalLoop() {
+	for req := range evalCh {
+		p := ai.WinProbability(req.b, req.toMove)
+		evalMu.Lock()
+		// 計算している間に局面が変わっていたら出さない
+		if req.gen == evalGen {
+			blackWinRate = p
+		}
+		evalMu.Unlock()
+	}
+}
+
+func requestEval(b *board.Board, toMove int) {
+	evalMu.Lock()
+	evalGen++
+	req := evalRequest{board.Copy(b), toMove, evalGen}
+	evalMu.Unlock()
+	// 計算が追いつかないときは古い依頼を捨てる
+	select {
+	case <-evalCh:
+	default:
+	}
+	evalCh <- req
+}
+
+// 勝率を決め打ちする。計算中の依頼の結果は捨てる
+func setWinRate(p float64) {
+	evalMu.Lock()
+	evalGen++
+	blackWinRate = p
+	evalMu.Unlock()
+}
+
+// 盤の上に黒の勝率の分だけ黒く塗ったバーを出す
+func updateEvalBar(sz size.Event) {
+	if evalBlackN == nil {
+		return
+	}
+	evalMu.Lock()
+	p := float32(blackWinRate)
+	evalMu.Unlock()
+
+	w := float32(sz.WidthPt)
+	h := float32(8)
+	top := float32((sz.HeightPt-sz.WidthPt)/2) - h*2
+	eng.SetTransform(evalBlackN, f32.Affine{
+		{w * p, 0, 0},
+		{0, h, top},
+	})
+	eng.SetTransform(evalWhiteN, f32.Affine{
+		{w * (1 - p), 0, w * p},
+		{0, h, top},
+	})
 }
 
 const (
@@ -326,3 +1152,91 @@ func loadGoisiTextures(glctx gl.Context) []sprite.SubTex {
 		texBlack: sprite.SubTex{t, image.Rect(50, 0, 99, 49)},
 	}
 }
+
+const (
+	texEvalBlack = iota
+	texEvalWhite
+)
+
+func loadEvalTextures() []sprite.SubTex {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			if x < 10 {
+				img.SetRGBA(x, y, color.RGBA{0x10, 0x10, 0x10, 0xff})
+			} else {
+				img.SetRGBA(x, y, color.RGBA{0xf0, 0xf0, 0xf0, 0xff})
+			}
+		}
+	}
+	t, err := eng.LoadTexture(img)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return []sprite.SubTex{
+		texEvalBlack: sprite.SubTex{t, image.Rect(1, 1, 9, 9)},
+		texEvalWhite: sprite.SubTex{t, image.Rect(11, 1, 19, 9)},
+	}
+}
+
+const (
+	markBlackThree = iota
+	markBlackFour
+	markWhiteThree
+	markWhiteFour
+	markBlunder
+	markMissedWin
+	markBest
+	markBook
+)
+
+func markerIndex(t board.Threat) int {
+	if t.Which == BLACK {
+		if t.Kind == board.ThreatFour {
+			return markBlackFour
+		}
+		return markBlackThree
+	}
+	if t.Kind == board.ThreatFour {
+		return markWhiteFour
+	}
+	return markWhiteThree
+}
+
+// 印の画像はアセットを使わずにここで描く
+func loadMarkerTextures() []sprite.SubTex {
+	colors := []color.RGBA{
+		markBlackThree: {0x20, 0x60, 0xff, 0xff},
+		markBlackFour:  {0xff, 0x20, 0x20, 0xff},
+		markWhiteThree: {0x20, 0xc0, 0x40, 0xff},
+		markWhiteFour:  {0xff, 0x90, 0x00, 0xff},
+		markBlunder:    {0xe0, 0x00, 0xe0, 0xff},
+		markMissedWin:  {0xff, 0xe0, 0x00, 0xff},
+		markBest:       {0x00, 0xd0, 0xd0, 0xff},
+		markBook:       {0x80, 0x80, 0x80, 0xff},
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 50*len(colors), 50))
+	for i, c := range colors {
+		// 石の縁に重なる輪を描く
+		for y := 0; y < 49; y++ {
+			for x := 0; x < 49; x++ {
+				dx, dy := x-24, y-24
+				d := dx*dx + dy*dy
+				if d >= 19*19 && d <= 23*23 {
+					img.SetRGBA(50*i+x, y, c)
+				}
+			}
+		}
+	}
+	t, err := eng.LoadTexture(img)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	texs := make([]sprite.SubTex, len(colors))
+	for i := range colors {
+		texs[i] = sprite.SubTex{t, image.Rect(50*i, 0, 50*i+49, 49)}
+	}
+	return texs
+}
@@ -0,0 +1,182 @@
+package network
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"../ai"
+	"../board"
+)
+
+// ボット(エンジンが打つ対局者)のメッセージ
+// ボットはRegisterBotでロビーに入り、ふつうの対局者と同じstart, move, clock, resultを受け取って打つ
+const (
+	MsgChallenge = "challenge" // ボットに対局を申し込む。ボットは断らない
+)
+
+var (
+	ErrNotBot  = errors.New("network: no such bot")
+	ErrBotBusy = errors.New("network: bot is playing")
+)
+
+func (r *room) hasBot() bool {
+	for _, c := range r.players {
+		if c != nil && c.bot {
+			return true
+		}
+	}
+	return false
+}
+
+// ボットとの対局を始める
+// m.Colorが白なら申し込んだ側が白を持ち、それ以外なら黒を持つ
+// ボットが対局を作って相手を待っているなら、その対局はやめてこちらを始める
+func (s *Server) challenge(cl *client, m Message) error {
+	if !cl.registered {
+		return ErrNotRegistered
+	}
+	if cl.room != nil {
+		return ErrJoined
+	}
+	bot := s.players[m.Name]
+	if bot == nil || !bot.bot || bot == cl {
+		return ErrNotBot
+	}
+	if r := bot.room; r != nil {
+		if r.begun {
+			return ErrBotBusy
+		}
+		delete(s.rooms, r.id)
+		bot.room = nil
+	}
+	st, err := gameSettings(m)
+	if err != nil {
+		return err
+	}
+	r := s.newRoom("", st)
+	first, second := cl, bot
+	if m.Color == board.White {
+		first, second = bot, cl
+	}
+	s.seat(first, r)
+	s.seat(second, r)
+	return nil
+}
+
+// ボットとしてロビーに名前を登録する
+func (c *Client) RegisterBot(name string) error {
+	return c.send(Message{Type: MsgRegister, Name: name, Bot: true})
+}
+
+// ボットに対局を申し込む。colorは持ちたい色(Spaceなら黒)
+// 始まるとMsgStartが届く
+func (c *Client) Challenge(bot string, size int, rule board.Rule, color int) error {
+	return c.send(Message{Type: MsgChallenge, Name: bot, Size: size, Rule: rule.String(), Time: c.Time, Color: color})
+}
+
+// ai.Engineをボットとしてサーバーにつなぎ、対局させる
+// 手番が来るたびに残り時間を添えてエンジンに手を聞く
+// 引き分けや入れ替えの提案は断る
+type Bot struct {
+	Client *Client
+	Engine ai.Engine
+	// Sizeが0でなければ、対局していない間はこの大きさ、ルール、
+	// Client.Timeの持ち時間で対局を作って相手を待つ
+	Size int
+	Rule board.Rule
+
+	moved  int // 最後に打ったときの手数
+	failed int // 最後に手を断られたときの手数
+}
+
+func NewBot(c *Client, e ai.Engine) *Bot {
+	return &Bot{Client: c, Engine: e, moved: -1, failed: -1}
+}
+
+// nameで登録し、接続が切れるまで打ち続ける
+func (b *Bot) Run(name string) error {
+	if err := b.Client.RegisterBot(name); err != nil {
+		return err
+	}
+	for {
+		m, err := b.Client.Next()
+		if err != nil {
+			// 読めなかったのなら接続が切れている
+			if m.Type == "" {
+				return err
+			}
+			log.Printf("network: bot %s: %v", name, err)
+			continue
+		}
+		if err := b.handle(m); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *Bot) handle(m Message) error {
+	c := b.Client
+	switch m.Type {
+	case MsgRegister:
+		return b.seek()
+	case MsgStart:
+		b.moved, b.failed = -1, -1
+		log.Printf("network: bot game %s started: %s - %s", m.Game, m.Black, m.White)
+		return b.play()
+	case MsgSync, MsgResume, MsgMove:
+		return b.play()
+	case MsgDraw, MsgSwap:
+		return c.Decline()
+	case MsgResult:
+		log.Printf("network: bot game %s ended: %s (%s)", m.Game, m.Result, m.Text)
+		return b.seek()
+	case MsgError:
+		log.Printf("network: bot: %s", m.Error)
+		return b.rejected()
+	}
+	return nil
+}
+
+// 打った手が届かないうちにエラーが来たら、その手は断られている
+// 局面を問い合わせ直して打ち直し、同じ局面で二度断られたら投了する
+func (b *Bot) rejected() error {
+	c := b.Client
+	g := c.Game
+	if g == nil || g.Over() || g.Turn != c.Color || len(g.Moves) != b.moved {
+		return nil
+	}
+	b.moved = -1
+	if b.failed == len(g.Moves) {
+		return c.Resign()
+	}
+	b.failed = len(g.Moves)
+	return c.Sync()
+}
+
+// 手番ならエンジンに手を聞いて打つ
+func (b *Bot) play() error {
+	c := b.Client
+	g := c.Game
+	if g == nil || g.Over() || g.Turn != c.Color || len(g.Moves) == b.moved {
+		return nil
+	}
+	var left time.Duration
+	if c.Clock != nil {
+		left = c.Clock.Left(c.Color)
+	}
+	p := b.Engine.Move(g.Board, g.Turn, left)
+	b.moved = len(g.Moves)
+	// 打てない手しか返さなければ投了する
+	if board.At(g.Board, p.X, p.Y) != board.Space {
+		return c.Resign()
+	}
+	return c.Play(p.X, p.Y)
+}
+
+func (b *Bot) seek() error {
+	if b.Size == 0 {
+		return nil
+	}
+	return b.Client.Create("", b.Size, b.Rule)
+}
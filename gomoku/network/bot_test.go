@@ -0,0 +1,67 @@
+package network
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"../board"
+	"../record"
+)
+
+// 初めのtricks回は、手元の盤から石を消してその点を返す(サーバーには断られる)
+type trickEngine struct {
+	mu     sync.Mutex
+	tricks int
+	calls  int
+}
+
+func (e *trickEngine) Move(b *board.Board, which int, left time.Duration) board.Point {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls++
+	if e.calls <= e.tricks {
+		p := board.Point{X: 4, Y: 4}
+		board.Set(b, p.X, p.Y, board.Space)
+		return p
+	}
+	return board.Point{X: 3, Y: 3}
+}
+
+func startBot(t *testing.T, addr string, e *trickEngine) *Client {
+	t.Helper()
+	bot := NewBot(dial(t, addr), e)
+	go bot.Run("robot")
+	alice := register(t, addr, "alice", 0)
+	waitFor(t, func() bool {
+		alice.Players()
+		return len(expect(t, alice, MsgPlayers).Players) == 2
+	})
+	alice.Challenge("robot", 9, board.Freestyle, board.Black)
+	if m := expect(t, alice, MsgStart); m.White != "robot" {
+		t.Fatalf("start = %+v", m)
+	}
+	play(t, alice, "e5")
+	expect(t, alice, MsgMove)
+	return alice
+}
+
+func TestBotRetriesRejectedMove(t *testing.T) {
+	_, addr := startServer(t)
+	e := &trickEngine{tricks: 1}
+	alice := startBot(t, addr, e)
+	m := expect(t, alice, MsgMove)
+	if m.Move != "d4" || m.Color != board.White {
+		t.Fatalf("bot move = %+v", m)
+	}
+}
+
+func TestBotResignsWhenRejectedTwice(t *testing.T) {
+	_, addr := startServer(t)
+	e := &trickEngine{tricks: 2}
+	alice := startBot(t, addr, e)
+	m := expect(t, alice, MsgMove, MsgResult)
+	if m.Type != MsgResult || m.Result != record.BlackWin+"R" {
+		t.Fatalf("got %+v", m)
+	}
+}
@@ -0,0 +1,286 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"../board"
+	"../game"
+)
+
+// 届いた手を反映せずに、対局の状態を問い合わせ直している
+var ErrResync = errors.New("network: missed moves, resyncing")
+
+// サーバーにつないで対局するクライアント
+// Nextで受け取ったメッセージに合わせて手元のGameを進める
+type Client struct {
+	mu    sync.Mutex // connを張り直すとき
+	conn  *Conn
+	ID    string
+	Color int
+	Black string
+	White string
+	Token string     // つなぎ直すための合言葉
+	Time  string     // Join, Create, Matchで頼む持ち時間(空なら無し)
+	Game  *game.Game // 対局が始まるまではnil
+	// 表示用の時計。時間切れはサーバーが決めるので、Gameとは別に持つ
+	Clock *game.Clock
+}
+
+func Dial(addr string) (*Client, error) {
+	c, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn := NewConn(c)
+	conn.KeepAlive(pingInterval)
+	return &Client{conn: conn}, nil
+}
+
+// idが空ならサーバーが相手を探す
+func (c *Client) Join(name string, id string, size int, rule board.Rule) error {
+	return c.send(Message{Type: MsgJoin, Name: name, Game: id, Size: size, Rule: rule.String(), Time: c.Time})
+}
+
+// 次のメッセージを待ち、start, sync, watch, move, result, 提案なら手元の対局に反映する
+// サーバーから来たエラーはMsgErrorのまま返す
+// 手を取りこぼしていたらErrResyncを返し、あとで届くsyncで並べ直す
+func (c *Client) Next() (Message, error) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	m, err := conn.Receive()
+	if err != nil {
+		return m, err
+	}
+	switch m.Type {
+	case MsgStart, MsgSync, MsgWatch, MsgResume:
+		// つなぎ直しの返事は、手順が入っていなければ続きの手が後から届く
+		if m.Start == "" {
+			break
+		}
+		g, err := GameOf(m)
+		if err != nil {
+			return m, err
+		}
+		c.ID, c.Color, c.Black, c.White = m.Game, m.Color, m.Black, m.White
+		if m.Token != "" {
+			c.Token = m.Token
+		}
+		c.Game = g
+		c.Clock, err = ClockOf(m)
+		if err != nil {
+			return m, err
+		}
+		SetClock(c.Clock, m, g.Turn)
+	case MsgMove:
+		if c.Game == nil {
+			return m, ErrNotStarted
+		}
+		// 取りこぼしていたら問い合わせ直す。この手は反映しないのでErrResyncを返す
+		if m.Seq != len(c.Game.Moves)+1 {
+			if err := c.Sync(); err != nil {
+				return m, err
+			}
+			return m, ErrResync
+		}
+		p, err := board.ParsePoint(m.Move)
+		if err != nil {
+			return m, err
+		}
+		if err := c.Game.Play(p.X, p.Y); err != nil {
+			return m, fmt.Errorf("network: server sent unplayable move %s: %v", m.Move, err)
+		}
+		SetClock(c.Clock, m, c.Game.Turn)
+	case MsgPost:
+		// 開いている通信対局の手だけ反映する
+		if c.Game == nil || m.Game != c.ID {
+			break
+		}
+		if m.Seq != len(c.Game.Moves)+1 {
+			if err := c.Fetch(m.Game, c.Token); err != nil {
+				return m, err
+			}
+			return m, ErrResync
+		}
+		p, err := board.ParsePoint(m.Move)
+		if err != nil {
+			return m, err
+		}
+		if err := c.Game.Play(p.X, p.Y); err != nil {
+			return m, fmt.Errorf("network: server sent unplayable move %s: %v", m.Move, err)
+		}
+	case MsgDraw, MsgSwap:
+		if c.Game != nil {
+			kind := game.DrawOffer
+			if m.Type == MsgSwap {
+				kind = game.SwapOffer
+			}
+			c.Game.Offer = game.Offer{Kind: kind, By: m.Color}
+		}
+	case MsgDecline:
+		if c.Game != nil {
+			c.Game.Offer = game.Offer{}
+		}
+	case MsgClock:
+		if c.Game != nil {
+			SetClock(c.Clock, m, c.Game.Turn)
+		}
+	case MsgResult:
+		if c.Game != nil {
+			c.Game.Result = m.Result
+			c.Game.Ending = game.ParseEnding(m.Text, m.Result)
+			c.Game.Offer = game.Offer{}
+		}
+		if c.Clock != nil {
+			c.Clock.Pause()
+		}
+	}
+	return m, nil
+}
+
+// 切れた接続を張り直して対局に戻る
+// Nextを呼んでいるのと同じgoroutineから呼ぶ
+func (c *Client) Reconnect(addr string) error {
+	if c.Game == nil || c.Token == "" {
+		return ErrNotJoined
+	}
+	nc, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.conn.Close()
+	c.conn = NewConn(nc)
+	c.conn.KeepAlive(pingInterval)
+	c.mu.Unlock()
+	return c.send(Message{Type: MsgResume, Game: c.ID, Token: c.Token, Seq: len(c.Game.Moves)})
+}
+
+func (c *Client) Play(x int, y int) error {
+	return c.send(Message{Type: MsgMove, Move: board.FormatPoint(board.Point{X: x, Y: y})})
+}
+
+func (c *Client) Resign() error {
+	return c.send(Message{Type: MsgResign})
+}
+
+func (c *Client) OfferDraw() error {
+	return c.send(Message{Type: MsgDraw})
+}
+
+// 打ち始める前に黒と白の入れ替えを頼む
+// 受けられるとMsgSyncで新しい色と手順が届く
+func (c *Client) OfferSwap() error {
+	return c.send(Message{Type: MsgSwap})
+}
+
+// 相手の引き分けや入れ替えの提案を受ける
+func (c *Client) Accept() error {
+	return c.send(Message{Type: MsgAccept})
+}
+
+func (c *Client) Decline() error {
+	return c.send(Message{Type: MsgDecline})
+}
+
+// 五を作れる所がなくなったので引き分けにする
+func (c *Client) ClaimDraw() error {
+	return c.send(Message{Type: MsgClaim})
+}
+
+// game.AbortMoves手より前なら、勝ち負けなしで対局をやめる
+func (c *Client) Abort() error {
+	return c.send(Message{Type: MsgAbort})
+}
+
+func (c *Client) Chat(text string) error {
+	return c.send(Message{Type: MsgChat, Text: text})
+}
+
+func (c *Client) Sync() error {
+	return c.send(Message{Type: MsgSync})
+}
+
+// 観戦を始める。返事のMsgWatchで手元の対局が始めから並ぶ
+func (c *Client) Watch(id string) error {
+	return c.send(Message{Type: MsgWatch, Game: id})
+}
+
+func (c *Client) Unwatch() error {
+	return c.send(Message{Type: MsgUnwatch})
+}
+
+// ロビーに名前を登録する
+// ratingはサーバーがレーティングを持っていなければ使われる
+func (c *Client) Register(name string, rating float64) error {
+	return c.send(Message{Type: MsgRegister, Name: name, Rating: rating})
+}
+
+// 相手を待っている対局の一覧を頼む(返事はMsgGames)
+// sizeが0、ruleが空なら絞らない
+func (c *Client) List(size int, rule string) error {
+	return c.send(Message{Type: MsgList, Size: size, Rule: rule})
+}
+
+// ロビーにいる対局者の一覧を頼む(返事はMsgPlayers)
+func (c *Client) Players() error {
+	return c.send(Message{Type: MsgPlayers})
+}
+
+// 対局を作って相手を待つ(idが空ならサーバーが付ける)
+func (c *Client) Create(id string, size int, rule board.Rule) error {
+	return c.send(Message{Type: MsgCreate, Game: id, Size: size, Rule: rule.String(), Time: c.Time})
+}
+
+// レーティングの近い相手を探してもらう
+func (c *Client) Match(size int, rule board.Rule) error {
+	return c.send(Message{Type: MsgMatch, Size: size, Rule: rule.String(), Time: c.Time})
+}
+
+func (c *Client) CancelMatch() error {
+	return c.send(Message{Type: MsgCancel})
+}
+
+// 通信対局を作るか、相手を待っている通信対局に入る(idが空なら探す)
+// Registerしてから呼ぶ。作ったときはMsgSync、始まったときはMsgStartが届く
+// 返事のTokenはその対局の合言葉で、Post, Fetch, ResignCorrespondenceに要る
+func (c *Client) Correspond(id string, size int, rule board.Rule) error {
+	return c.send(Message{Type: MsgCorrespond, Game: id, Size: size, Rule: rule.String()})
+}
+
+// 通信対局に一手送る
+func (c *Client) Post(id string, token string, x int, y int) error {
+	return c.send(Message{Type: MsgPost, Game: id, Token: token, Move: board.FormatPoint(board.Point{X: x, Y: y})})
+}
+
+// 通信対局を開く。返事のMsgSyncで手元の対局が並ぶ
+func (c *Client) Fetch(id string, token string) error {
+	return c.send(Message{Type: MsgFetch, Game: id, Token: token})
+}
+
+// 通信対局を投了する
+func (c *Client) ResignCorrespondence(id string, token string) error {
+	return c.send(Message{Type: MsgResign, Game: id, Token: token})
+}
+
+// 自分の通信対局の一覧を頼む(返事はMsgMyGames)
+func (c *Client) MyGames() error {
+	return c.send(Message{Type: MsgMyGames})
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.Close()
+}
+
+func (c *Client) send(m Message) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	return conn.Send(m)
+}
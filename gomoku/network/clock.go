@@ -0,0 +1,90 @@
+package network
+
+import (
+	"time"
+
+	"../game"
+)
+
+// 切れている間の時計の扱い
+type ClockPolicy int
+
+const (
+	ClockPause ClockPolicy = iota // 切れている間は手番の時計を止める
+	ClockRun                      // 切れていても時計を進める
+)
+
+// 時計はサーバーのものが正しい
+// 手番の側の時間が切れるころに確かめ、切れていれば負けにする
+func (s *Server) armClock(r *room) {
+	g := r.game
+	if g.Clock == nil || !g.Clock.Running || g.Over() {
+		return
+	}
+	r.tick++
+	tick := r.tick
+	left := g.Clock.Left(g.Turn)
+	time.AfterFunc(left+10*time.Millisecond, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.tick != tick || g.Over() {
+			return
+		}
+		if g.CheckTime() {
+			s.finish(r, g.Clock.Flagged)
+			return
+		}
+		s.armClock(r)
+	})
+}
+
+// 切れた対局者を待つ間、時計を止める
+func (s *Server) pauseClock(r *room) {
+	if r.game.Clock == nil {
+		return
+	}
+	r.game.Clock.Pause()
+	r.tick++
+	r.broadcast(Message{Type: MsgClock, Game: r.id, Status: StatusOffline, Clock: timesOf(r.game)})
+}
+
+func (s *Server) resumeClock(r *room) {
+	c := r.game.Clock
+	if c == nil || c.Running || r.game.Over() {
+		return
+	}
+	c.Resume()
+	s.armClock(r)
+	r.broadcast(Message{Type: MsgClock, Game: r.id, Status: StatusPlaying, Clock: timesOf(r.game)})
+}
+
+// 対局の始まりのメッセージから、持ち時間の表示用の時計を作る
+// 持ち時間がなければnil
+func ClockOf(m Message) (*game.Clock, error) {
+	if m.Time == "" {
+		return nil, nil
+	}
+	tc, err := game.ParseTimeControl(m.Time)
+	if err != nil {
+		return nil, err
+	}
+	c := game.NewClock(tc)
+	SetClock(c, m, 0)
+	return c, nil
+}
+
+// 届いた残り時間をcに写し、turnの側の時計を動かす
+// 時間切れはサーバーが決めるので、手元の時計は表示にだけ使う
+func SetClock(c *game.Clock, m Message, turn int) {
+	if c == nil || len(m.Clock) != 2 {
+		return
+	}
+	c.Times[0], c.Times[1] = m.Clock[0], m.Clock[1]
+	if turn == 0 {
+		return
+	}
+	c.Start(turn)
+	if m.Result != "" || (m.Type == MsgClock && m.Status == StatusOffline) {
+		c.Pause()
+	}
+}
@@ -0,0 +1,415 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"../board"
+	"../game"
+)
+
+// 通信対局(一局に何日もかける対局)のメッセージ
+// 通信対局はロビーに登録した名前で打つ。部屋には入らず、いつでも一手ずつ送る
+const (
+	MsgCorrespond = "correspond" // 通信対局を作る。Gameがあればその対局に、なければ待っている対局に入る
+	MsgPost       = "post"       // 通信対局に一手送る。相手がロビーにいれば相手にも届く
+	MsgFetch      = "fetch"      // 通信対局の状態を問い合わせる(返事はMsgSync)
+	MsgMyGames    = "mygames"    // 自分の通信対局のうち終わっていないものを問い合わせる/知らせる
+)
+
+// 一手にかけられる時間の既定値
+const DefaultMoveDeadline = 72 * time.Hour
+
+var (
+	ErrNoCorrespondence = errors.New("network: correspondence play is not enabled")
+	ErrNotYourGame      = errors.New("network: not your game")
+)
+
+// 通信対局一つ分
+type corrGame struct {
+	id       string
+	names    [2]string // 黒、白の順。相手が来るまで白は空
+	game     *game.Game
+	perMove  time.Duration
+	deadline time.Time // 手番の側がこれまでに打たなければ負け。始まるまではゼロ
+	created  time.Time
+	// 席ごとの合言葉。名前は確かめようがないので、打つ、投了する、開くにはこれが要る
+	tokens [2]string
+}
+
+// 保存形式
+type savedCorr struct {
+	ID       string        `json:"id"`
+	Black    string        `json:"black"`
+	White    string        `json:"white,omitempty"`
+	Start    string        `json:"start"` // board.FormatPositionの表記
+	Moves    []string      `json:"moves"`
+	Result   string        `json:"result,omitempty"`
+	Ending   string        `json:"ending,omitempty"`
+	PerMove  time.Duration `json:"per_move"`
+	Deadline time.Time     `json:"deadline"`
+	Created  time.Time     `json:"created"`
+	Tokens   [2]string     `json:"tokens"`
+}
+
+func (c *corrGame) started() bool {
+	return c.names[1] != ""
+}
+
+func (c *corrGame) color(name string) int {
+	for i, n := range c.names {
+		if n == name {
+			return i + 1
+		}
+	}
+	return board.Space
+}
+
+func (c *corrGame) info() GameInfo {
+	gi := GameInfo{
+		ID:      c.id,
+		Size:    c.game.Size,
+		Rule:    c.game.Rule.String(),
+		Black:   c.names[0],
+		White:   c.names[1],
+		Moves:   len(c.game.Moves),
+		Started: c.started(),
+		Result:  c.game.Result,
+	}
+	if c.started() && !c.game.Over() {
+		gi.Turn = c.game.Turn
+		d := c.deadline
+		gi.Deadline = &d
+	}
+	return gi
+}
+
+func (c *corrGame) sync() Message {
+	m := syncMessage(c.id, c.game)
+	m.Black, m.White = c.names[0], c.names[1]
+	if c.started() && !c.game.Over() {
+		d := c.deadline
+		m.Deadline = &d
+	}
+	return m
+}
+
+// colorの対局者に送る状態。その席の合言葉を入れる
+func (c *corrGame) syncFor(color int) Message {
+	m := c.sync()
+	m.Color = color
+	m.Token = c.tokens[color-1]
+	return m
+}
+
+// dirに保存してある通信対局を読み込み、以後の通信対局をそこに保存する
+// 呼ばなければ通信対局はできない
+func (s *Server) OpenCorrespondence(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	games := map[string]*corrGame{}
+	for _, name := range names {
+		c, err := loadCorr(name)
+		if err != nil {
+			return err
+		}
+		games[c.id] = c
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.corrDir = dir
+	s.corr = games
+	return nil
+}
+
+func loadCorr(path string) (*corrGame, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc savedCorr
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, err
+	}
+	g, err := GameOf(Message{Start: sc.Start, Moves: sc.Moves, Result: sc.Result})
+	if err != nil {
+		return nil, err
+	}
+	if sc.Ending != "" {
+		g.Ending = game.ParseEnding(sc.Ending, sc.Result)
+	}
+	c := &corrGame{
+		id:       sc.ID,
+		names:    [2]string{sc.Black, sc.White},
+		game:     g,
+		perMove:  sc.PerMove,
+		deadline: sc.Deadline,
+		created:  sc.Created,
+		tokens:   sc.Tokens,
+	}
+	// 合言葉のない席には誰も戻れないので、作り直して記録に残す
+	for i, t := range c.tokens {
+		if t == "" && c.names[i] != "" {
+			c.tokens[i] = newToken()
+			log.Printf("network: correspondence %s: new token for %s", c.id, c.names[i])
+		}
+	}
+	return c, nil
+}
+
+func (s *Server) saveCorr(c *corrGame) {
+	m := syncMessage(c.id, c.game)
+	sc := savedCorr{
+		ID:       c.id,
+		Black:    c.names[0],
+		White:    c.names[1],
+		Start:    m.Start,
+		Moves:    m.Moves,
+		Result:   c.game.Result,
+		PerMove:  c.perMove,
+		Deadline: c.deadline,
+		Created:  c.created,
+		Tokens:   c.tokens,
+	}
+	if c.game.Over() {
+		sc.Ending = c.game.Ending.String()
+	}
+	data, err := json.MarshalIndent(sc, "", "\t")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	// 途中で落ちても壊れないように書いてから置き換える
+	path := filepath.Join(s.corrDir, c.id+".json")
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Println(err)
+	}
+}
+
+func (s *Server) corrFor(cl *client) error {
+	if s.corr == nil {
+		return ErrNoCorrespondence
+	}
+	if !cl.registered {
+		return ErrNotRegistered
+	}
+	return nil
+}
+
+// 通信対局を作るか、相手を待っている通信対局に入る
+func (s *Server) correspond(cl *client, m Message) error {
+	if err := s.corrFor(cl); err != nil {
+		return err
+	}
+	st, err := gameSettings(m)
+	if err != nil {
+		return err
+	}
+	var c *corrGame
+	if m.Game != "" {
+		if c = s.corr[m.Game]; c == nil {
+			return ErrNoGame
+		}
+		if c.started() || c.names[0] == cl.name {
+			return ErrGameFull
+		}
+	} else {
+		for _, w := range s.corr {
+			if !w.started() && w.names[0] != cl.name && w.game.Size == st.size && w.game.Rule == st.rule {
+				c = w
+				break
+			}
+		}
+	}
+	now := time.Now()
+	if c == nil {
+		g := game.New(st.size)
+		g.Rule = st.rule
+		perMove := s.MoveDeadline
+		if perMove <= 0 {
+			perMove = DefaultMoveDeadline
+		}
+		c = &corrGame{id: newToken()[:8], game: g, perMove: perMove, created: now}
+		c.names[0] = cl.name
+		c.tokens[0] = newToken()
+		s.corr[c.id] = c
+		s.saveCorr(c)
+		cl.conn.Send(c.syncFor(board.Black))
+		return nil
+	}
+	c.names[1] = cl.name
+	c.tokens[1] = newToken()
+	c.deadline = now.Add(c.perMove)
+	s.saveCorr(c)
+	log.Printf("network: correspondence %s started: %s - %s", c.id, c.names[0], c.names[1])
+	// 入った側はロビーにいなくても返事で合言葉を受け取る
+	start := c.syncFor(board.White)
+	start.Type = MsgStart
+	cl.conn.Send(start)
+	if p := s.players[c.names[0]]; p != nil && p != cl {
+		start := c.syncFor(board.Black)
+		start.Type = MsgStart
+		p.conn.Send(start)
+	}
+	return nil
+}
+
+// 通信対局に一手打つ
+func (s *Server) post(cl *client, m Message) error {
+	c, color, err := s.corrGameFor(cl, m)
+	if err != nil {
+		return err
+	}
+	g := c.game
+	if !c.started() {
+		return ErrNotStarted
+	}
+	if g.Over() {
+		return game.ErrGameOver
+	}
+	if g.Turn != color {
+		return ErrNotYourTurn
+	}
+	p, err := board.ParsePoint(m.Move)
+	if err != nil {
+		return err
+	}
+	if err := g.Play(p.X, p.Y); err != nil {
+		return err
+	}
+	c.deadline = time.Now().Add(c.perMove)
+	mv := moveMessage(c.id, g.Moves[len(g.Moves)-1], len(g.Moves))
+	mv.Type = MsgPost
+	if !g.Over() {
+		d := c.deadline
+		mv.Deadline = &d
+	}
+	s.notifyCorr(c, func(int) Message { return mv })
+	if g.Over() {
+		s.finishCorr(c, 0)
+		return nil
+	}
+	s.saveCorr(c)
+	return nil
+}
+
+func (s *Server) corrResign(cl *client, m Message) error {
+	c, color, err := s.corrGameFor(cl, m)
+	if err != nil {
+		return err
+	}
+	// 白が来る前に投了すると、相手のいない対局が記録に残ってしまう
+	if !c.started() {
+		return ErrNotStarted
+	}
+	if err := c.game.Resign(color); err != nil {
+		return err
+	}
+	s.finishCorr(c, color)
+	return nil
+}
+
+func (s *Server) fetch(cl *client, m Message) error {
+	c, color, err := s.corrGameFor(cl, m)
+	if err != nil {
+		return err
+	}
+	return cl.conn.Send(c.syncFor(color))
+}
+
+// 自分の通信対局のうち、終わっていないものを新しい順に返す
+func (s *Server) myGames(cl *client) error {
+	if err := s.corrFor(cl); err != nil {
+		return err
+	}
+	var list []*corrGame
+	for _, c := range s.corr {
+		if c.color(cl.name) != board.Space && !c.game.Over() {
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].created.After(list[j].created) })
+	games := []GameInfo{}
+	for _, c := range list {
+		games = append(games, c.info())
+	}
+	return cl.conn.Send(Message{Type: MsgMyGames, Games: games})
+}
+
+// m.Gameの対局のうちm.Tokenの席
+// 合言葉が合わなければ、名前が同じでもその席の対局者とは認めない
+func (s *Server) corrGameFor(cl *client, m Message) (*corrGame, int, error) {
+	if err := s.corrFor(cl); err != nil {
+		return nil, 0, err
+	}
+	c := s.corr[m.Game]
+	if c == nil {
+		return nil, 0, ErrNoGame
+	}
+	color := c.color(cl.name)
+	if color == board.Space {
+		return nil, 0, ErrNotYourGame
+	}
+	if m.Token == "" || m.Token != c.tokens[color-1] {
+		return nil, 0, ErrBadToken
+	}
+	return c, color, nil
+}
+
+// ロビーにいる対局者に知らせる
+func (s *Server) notifyCorr(c *corrGame, msg func(color int) Message) {
+	for i, name := range c.names {
+		if p := s.players[name]; name != "" && p != nil {
+			p.conn.Send(msg(i + 1))
+		}
+	}
+}
+
+// 終局を保存して知らせ、レーティングに反映する
+// colorは投了や時間切れで負けた側
+func (s *Server) finishCorr(c *corrGame, color int) {
+	g := c.game
+	c.deadline = time.Time{}
+	s.saveCorr(c)
+	result := Message{Type: MsgResult, Game: c.id, Result: g.Result, Color: color, Text: g.Ending.String()}
+	s.notifyCorr(c, func(int) Message { return result })
+	log.Printf("network: correspondence %s ended: %s (%s)", c.id, g.Result, g.Ending)
+	if s.Ratings == nil {
+		return
+	}
+	if err := s.Ratings.Record(c.names[0], c.names[1], g.Result, len(g.Moves)); err != nil {
+		log.Println(err)
+	} else if s.RatingsFile != "" {
+		if err := s.Ratings.Save(s.RatingsFile); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// 期限までに打たなかった側を負けにする
+func (s *Server) checkDeadlines(now time.Time) {
+	for _, c := range s.corr {
+		if !c.started() || c.game.Over() || c.deadline.IsZero() || now.Before(c.deadline) {
+			continue
+		}
+		loser := c.game.Turn
+		c.game.Result = game.TimeLoss(loser)
+		c.game.Ending = game.EndTime
+		s.finishCorr(c, loser)
+	}
+}
@@ -0,0 +1,229 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"../board"
+)
+
+// LANの中で、サーバーなしに相手を見つけて対局する
+// 対局を開く側は手元でServerを立てて自分も入り、UDPのマルチキャスト
+// (またはブロードキャスト)で対局があることを知らせ続ける
+// 見つけた側はそのアドレスにTCPでつなぎ、あとはいつもの手順で対局する
+const (
+	DefaultGroup     = "239.255.76.50:7651"
+	announceProto    = "gomoku/1"
+	announceInterval = time.Second
+	maxAnnounceSize  = 1024
+)
+
+var ErrNotFound = errors.New("network: no game found on LAN")
+
+// 相手を待っている対局の知らせ
+type Announcement struct {
+	Proto string `json:"proto"`
+	Game  string `json:"game"`
+	Name  string `json:"name"`
+	Size  int    `json:"size"`
+	Rule  string `json:"rule"`
+	Time  string `json:"time,omitempty"`
+	Port  int    `json:"port"` // 対局を待っているTCPのポート
+	Addr  string `json:"-"`    // 受け取った側で、送り主のアドレスとPortから埋める
+}
+
+// 知らせを一定の間隔で送り続ける
+type Beacon struct {
+	conn *net.UDPConn
+	data []byte
+	// nilでなければ送る前に確かめ、falseなら送らない
+	Open func() bool
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewBeacon(group string, a Announcement) (*Beacon, error) {
+	addr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	a.Proto = announceProto
+	data, err := json.Marshal(a)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Beacon{conn: conn, data: data, stop: make(chan struct{})}, nil
+}
+
+// Closeするまで送り続ける
+func (b *Beacon) Run() {
+	t := time.NewTicker(announceInterval)
+	defer t.Stop()
+	for {
+		if b.Open == nil || b.Open() {
+			b.conn.Write(b.data)
+		}
+		select {
+		case <-b.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (b *Beacon) Close() error {
+	b.once.Do(func() { close(b.stop) })
+	return b.conn.Close()
+}
+
+// 知らせを受け取る
+type Browser struct {
+	conn *net.UDPConn
+}
+
+// groupがマルチキャストのアドレスならそのグループに入り、
+// そうでなければ(ブロードキャストなら)そのポートで待つ
+func NewBrowser(group string) (*Browser, error) {
+	addr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return nil, err
+	}
+	var conn *net.UDPConn
+	if addr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp4", nil, addr)
+	} else {
+		conn, err = net.ListenUDP("udp4", &net.UDPAddr{Port: addr.Port})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Browser{conn: conn}, nil
+}
+
+// 次の知らせを待つ。ほかの形式のパケットは読み飛ばす
+func (br *Browser) Next() (Announcement, error) {
+	buf := make([]byte, maxAnnounceSize)
+	for {
+		n, from, err := br.conn.ReadFromUDP(buf)
+		if err != nil {
+			return Announcement{}, err
+		}
+		var a Announcement
+		if json.Unmarshal(buf[:n], &a) != nil || a.Proto != announceProto || a.Port <= 0 {
+			continue
+		}
+		a.Addr = net.JoinHostPort(from.IP.String(), strconv.Itoa(a.Port))
+		return a, nil
+	}
+}
+
+func (br *Browser) Close() error {
+	return br.conn.Close()
+}
+
+// waitの間に届いた知らせを、対局ごとに一つずつ集める
+func Discover(group string, wait time.Duration) ([]Announcement, error) {
+	br, err := NewBrowser(group)
+	if err != nil {
+		return nil, err
+	}
+	defer br.Close()
+	br.conn.SetReadDeadline(time.Now().Add(wait))
+	var found []Announcement
+	seen := map[string]bool{}
+	for {
+		a, err := br.Next()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return found, nil
+			}
+			return found, err
+		}
+		if key := a.Addr + " " + a.Game; !seen[key] {
+			seen[key] = true
+			found = append(found, a)
+		}
+	}
+}
+
+// LANで開いた対局
+type LANHost struct {
+	Server *Server
+	l      net.Listener
+	beacon *Beacon
+}
+
+// 手元でServerを立てて対局を開き、相手が来るまでgroupに知らせる
+// 返すClientは自分の席につないであるので、Nextで相手を待つ
+func HostLAN(group string, name string, size int, rule board.Rule, tc string) (*Client, *LANHost, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, nil, err
+	}
+	s := NewServer()
+	go s.Serve(l)
+	port := l.Addr().(*net.TCPAddr).Port
+	c, err := Dial(net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		l.Close()
+		return nil, nil, err
+	}
+	id := newToken()[:8]
+	c.Time = tc
+	if err := c.Join(name, id, size, rule); err != nil {
+		c.Close()
+		l.Close()
+		return nil, nil, err
+	}
+	b, err := NewBeacon(group, Announcement{Game: id, Name: name, Size: size, Rule: rule.String(), Time: tc, Port: port})
+	if err != nil {
+		c.Close()
+		l.Close()
+		return nil, nil, err
+	}
+	b.Open = func() bool { return s.Waiting(id) }
+	go b.Run()
+	return c, &LANHost{Server: s, l: l, beacon: b}, nil
+}
+
+// 知らせるのをやめ、対局を待つのもやめる
+func (h *LANHost) Close() error {
+	h.beacon.Close()
+	return h.l.Close()
+}
+
+// 見つけた対局につなぐ
+func JoinLAN(a Announcement, name string) (*Client, error) {
+	rule, err := board.ParseRule(a.Rule)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Dial(a.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Join(name, a.Game, a.Size, rule); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// idの対局が相手を待っているか
+func (s *Server) Waiting(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.rooms[id]
+	return r != nil && !r.begun
+}
@@ -0,0 +1,105 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"../board"
+)
+
+// テストではループバックのアドレスをグループの代わりにする
+func loopbackGroup(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+	return addr
+}
+
+// 知らせが二度は届くだけ待つ
+const discoverWait = 2*announceInterval + 500*time.Millisecond
+
+func TestBeaconDiscover(t *testing.T) {
+	group := loopbackGroup(t)
+	b, err := NewBeacon(group, Announcement{Game: "g1", Name: "alice", Size: 13, Rule: "renju", Time: "5m", Port: 7000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	go b.Run()
+
+	found, err := Discover(group, discoverWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 何度届いても対局ごとに一つ
+	if len(found) != 1 {
+		t.Fatalf("found %d announcements", len(found))
+	}
+	a := found[0]
+	if a.Proto != announceProto || a.Game != "g1" || a.Name != "alice" || a.Size != 13 || a.Rule != "renju" || a.Time != "5m" {
+		t.Fatalf("announcement = %+v", a)
+	}
+	if a.Addr != "127.0.0.1:7000" {
+		t.Fatalf("addr = %q", a.Addr)
+	}
+}
+
+func TestBeaconClosed(t *testing.T) {
+	group := loopbackGroup(t)
+	b, err := NewBeacon(group, Announcement{Game: "g1", Port: 7000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Open = func() bool { return false }
+	go b.Run()
+	defer b.Close()
+	found, err := Discover(group, discoverWait)
+	if err != nil || len(found) != 0 {
+		t.Fatalf("Discover = %v, %v", found, err)
+	}
+}
+
+func TestHostAndJoinLAN(t *testing.T) {
+	group := loopbackGroup(t)
+	host, h, err := HostLAN(group, "alice", 9, board.Renju, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	defer host.Close()
+
+	found, err := Discover(group, discoverWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Name != "alice" || found[0].Size != 9 || found[0].Rule != board.Renju.String() {
+		t.Fatalf("found = %+v", found)
+	}
+	guest, err := JoinLAN(found[0], "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer guest.Close()
+
+	for _, c := range []*Client{host, guest} {
+		m := expect(t, c, MsgStart)
+		if m.Black != "alice" || m.White != "bob" || m.Size != 9 || m.Rule != board.Renju.String() {
+			t.Fatalf("start = %+v", m)
+		}
+	}
+	if host.Color != board.Black || guest.Color != board.White {
+		t.Fatalf("colors = %d, %d", host.Color, guest.Color)
+	}
+	// 始まったら知らせるのをやめる
+	if h.Server.Waiting(found[0].Game) {
+		t.Fatal("game still waiting after start")
+	}
+	if found, _ := Discover(group, discoverWait); len(found) != 0 {
+		t.Fatalf("still announced: %+v", found)
+	}
+}
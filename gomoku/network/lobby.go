@@ -0,0 +1,275 @@
+package network
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"../rating"
+)
+
+// ロビーのメッセージ(Message.Type)
+const (
+	MsgRegister = "register" // 名前を登録してロビーに入る
+	MsgList     = "list"     // 相手を待っている対局の一覧を問い合わせる
+	MsgGames    = "games"    // 対局の一覧(サーバーから)
+	MsgPlayers  = "players"  // ロビーにいる対局者を問い合わせる/知らせる
+	MsgPresence = "presence" // 対局者の状態が変わった(サーバーから)
+	MsgCreate   = "create"   // 対局を作って相手を待つ
+	MsgMatch    = "match"    // レーティングの近い相手を探してもらう
+	MsgCancel   = "cancel"   // 相手探しをやめる
+)
+
+// 対局者の状態
+const (
+	StatusIdle    = "idle"
+	StatusWaiting = "waiting" // 対局を作ったか、相手を探している
+	StatusPlaying = "playing"
+	StatusOffline = "offline"
+)
+
+// レーティング差の初めの許容幅。30秒待つごとにこれだけ広げる
+const DefaultMatchRange = 200
+
+var (
+	ErrNotRegistered = errors.New("network: not registered")
+	ErrRegistered    = errors.New("network: already registered")
+	ErrGameExists    = errors.New("network: game already exists")
+	ErrNameTaken     = errors.New("network: name is in use")
+	errNoName        = errors.New("network: name is empty")
+)
+
+type GameInfo struct {
+	ID       string `json:"id"`
+	Size     int    `json:"size"`
+	Rule     string `json:"rule"`
+	Black    string `json:"black,omitempty"`
+	White    string `json:"white,omitempty"`
+	Moves    int    `json:"moves"`
+	Started  bool   `json:"started"`
+	Result   string `json:"result,omitempty"`
+	Time     string `json:"time,omitempty"`
+	Watchers int    `json:"watchers"`
+	Bot      bool   `json:"bot,omitempty"` // 対局者にボットがいる
+	// 通信対局の手番と、その側が打つ期限
+	Turn     int        `json:"turn,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+type PlayerInfo struct {
+	Name   string  `json:"name"`
+	Rating float64 `json:"rating"`
+	Status string  `json:"status"`
+	Bot    bool    `json:"bot,omitempty"`
+}
+
+type matchRequest struct {
+	cl       *client
+	settings settings
+	since    time.Time
+}
+
+// 待った時間に応じて広げたレーティング差の許容幅
+func (s *Server) window(req *matchRequest, now time.Time) float64 {
+	return s.MatchRange * (1 + math.Floor(now.Sub(req.since).Seconds()/30))
+}
+
+// 名前を登録する
+// 名前は確かめようがないので、つながっている対局者の名前は使わせない
+// 切れた対局の席に戻るのはMsgResumeの合言葉だけでできる
+func (s *Server) register(cl *client, m Message) error {
+	if cl.registered {
+		return ErrRegistered
+	}
+	if cl.room != nil {
+		return ErrJoined
+	}
+	if m.Name == "" {
+		return errNoName
+	}
+	if len(m.Name) > maxNameLength {
+		return errNameTooLong
+	}
+	if s.players[m.Name] != nil {
+		return ErrNameTaken
+	}
+	cl.name = m.Name
+	cl.registered = true
+	cl.bot = m.Bot
+	switch {
+	case s.Ratings != nil:
+		cl.rating = s.Ratings.Player(m.Name).Elo
+	case m.Rating != 0:
+		cl.rating = m.Rating
+	default:
+		cl.rating = rating.InitialElo
+	}
+	s.players[m.Name] = cl
+	cl.conn.Send(Message{Type: MsgRegister, Name: cl.name, Rating: cl.rating, Bot: cl.bot})
+	s.setStatus(cl, StatusIdle)
+	return nil
+}
+
+// 登録していない対局者が、ロビーにいる対局者の名前で対局に入らないようにする
+func (s *Server) checkName(cl *client, name string) error {
+	if cl.registered {
+		return nil
+	}
+	if len(name) > maxNameLength {
+		return errNameTooLong
+	}
+	if name != "" && s.players[name] != nil {
+		return ErrNameTaken
+	}
+	return nil
+}
+
+func (s *Server) setStatus(cl *client, status string) {
+	cl.status = status
+	s.presence(cl, status)
+}
+
+// ロビーにいるほかの対局者に状態を知らせる
+func (s *Server) presence(cl *client, status string) {
+	m := Message{Type: MsgPresence, Name: cl.name, Rating: cl.rating, Status: status, Bot: cl.bot}
+	for _, p := range s.players {
+		if p != cl {
+			p.conn.Send(m)
+		}
+	}
+}
+
+// 相手を待っている対局を、大きさとルールで絞って返す
+// StatusをStatusPlayingにすると観戦できる対局中のものを返す
+func (s *Server) list(cl *client, m Message) error {
+	var games []GameInfo
+	for _, r := range s.rooms {
+		if r.begun != (m.Status == StatusPlaying) {
+			continue
+		}
+		if m.Size != 0 && r.game.Size != m.Size {
+			continue
+		}
+		if m.Rule != "" && r.game.Rule.String() != m.Rule {
+			continue
+		}
+		games = append(games, r.info())
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].ID < games[j].ID })
+	return cl.conn.Send(Message{Type: MsgGames, Games: games})
+}
+
+func (s *Server) listPlayers(cl *client) error {
+	var players []PlayerInfo
+	for _, p := range s.players {
+		players = append(players, PlayerInfo{Name: p.name, Rating: p.rating, Status: p.status, Bot: p.bot})
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].Name < players[j].Name })
+	return cl.conn.Send(Message{Type: MsgPlayers, Players: players})
+}
+
+// 対局を作って相手を待つ
+func (s *Server) create(cl *client, m Message) error {
+	if cl.room != nil {
+		return ErrJoined
+	}
+	if _, ok := s.rooms[m.Game]; ok && m.Game != "" {
+		return ErrGameExists
+	}
+	if err := s.checkName(cl, m.Name); err != nil {
+		return err
+	}
+	if !cl.registered {
+		cl.name = m.Name
+	}
+	st, err := gameSettings(m)
+	if err != nil {
+		return err
+	}
+	r := s.newRoom(m.Game, st)
+	cl.conn.Send(Message{Type: MsgCreate, Game: r.id, Size: st.size, Rule: st.rule.String(), Time: st.time})
+	s.seat(cl, r)
+	return nil
+}
+
+// 相手探しの列に並ぶ
+func (s *Server) match(cl *client, m Message) error {
+	if !cl.registered {
+		return ErrNotRegistered
+	}
+	if cl.room != nil {
+		return ErrJoined
+	}
+	st, err := gameSettings(m)
+	if err != nil {
+		return err
+	}
+	s.removeFromQueue(cl)
+	s.queue = append(s.queue, &matchRequest{cl: cl, settings: st, since: time.Now()})
+	s.setStatus(cl, StatusWaiting)
+	s.pair(time.Now())
+	return nil
+}
+
+func (s *Server) cancelMatch(cl *client) error {
+	if s.removeFromQueue(cl) {
+		s.setStatus(cl, StatusIdle)
+	}
+	return nil
+}
+
+func (s *Server) removeFromQueue(cl *client) bool {
+	for i, req := range s.queue {
+		if req.cl == cl {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// 列に並んでいる中で、許容幅に収まる一番近い相手同士を組ませる
+func (s *Server) pair(now time.Time) {
+	for i := 0; i < len(s.queue); i++ {
+		a := s.queue[i]
+		best := -1
+		for j := i + 1; j < len(s.queue); j++ {
+			b := s.queue[j]
+			if a.settings != b.settings {
+				continue
+			}
+			d := math.Abs(a.cl.rating - b.cl.rating)
+			if d > s.window(a, now) || d > s.window(b, now) {
+				continue
+			}
+			if best < 0 || d < math.Abs(a.cl.rating-s.queue[best].cl.rating) {
+				best = j
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		b := s.queue[best]
+		s.queue = append(s.queue[:best], s.queue[best+1:]...)
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+		i--
+		// 先手が有利なので、レーティングの低い方を黒にする
+		if b.cl.rating < a.cl.rating {
+			a, b = b, a
+		}
+		r := s.newRoom("", a.settings)
+		s.seat(a.cl, r)
+		s.seat(b.cl, r)
+	}
+}
+
+// 待っている間に許容幅が広がるので、ときどき組ませ直す
+func (s *Server) matchLoop() {
+	for now := range time.Tick(5 * time.Second) {
+		s.mu.Lock()
+		s.pair(now)
+		s.checkDeadlines(now)
+		s.mu.Unlock()
+	}
+}
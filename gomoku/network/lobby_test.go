@@ -0,0 +1,236 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"../board"
+)
+
+func register(t *testing.T, addr string, name string, rating float64) *Client {
+	t.Helper()
+	c := dial(t, addr)
+	if err := c.Register(name, rating); err != nil {
+		t.Fatal(err)
+	}
+	if m := expect(t, c, MsgRegister, MsgError); m.Type != MsgRegister || m.Name != name || (rating != 0 && m.Rating != rating) {
+		t.Fatalf("register = %+v", m)
+	}
+	return c
+}
+
+func TestRegister(t *testing.T) {
+	_, addr := startServer(t)
+	alice := register(t, addr, "alice", 1500)
+	if err := alice.Register("alice2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if m := expect(t, alice, MsgError); m.Error != ErrRegistered.Error() {
+		t.Fatalf("error = %q", m.Error)
+	}
+
+	// つながっている対局者の名前は使えない
+	other := dial(t, addr)
+	other.Register("alice", 0)
+	if m := expect(t, other, MsgRegister, MsgError); m.Error != ErrNameTaken.Error() {
+		t.Fatalf("register = %+v", m)
+	}
+	// 登録せずに対局を作るときも同じ
+	other.Join("alice", "g1", 9, board.Freestyle)
+	if m := expect(t, other, MsgError); m.Error != ErrNameTaken.Error() {
+		t.Fatalf("error = %q", m.Error)
+	}
+	other.Register("", 0)
+	expect(t, other, MsgError)
+
+	// 切れたら使える
+	alice.Close()
+	waitFor(t, func() bool {
+		c, err := Dial(addr)
+		if err != nil {
+			return false
+		}
+		defer c.Close()
+		c.Register("alice", 0)
+		m, err := c.Next()
+		return err == nil && m.Type == MsgRegister
+	})
+}
+
+func TestListFilter(t *testing.T) {
+	_, addr := startServer(t)
+	alice := register(t, addr, "alice", 0)
+	bob := register(t, addr, "bob", 0)
+	carol := register(t, addr, "carol", 0)
+	alice.Create("a", 9, board.Freestyle)
+	expect(t, alice, MsgCreate)
+	bob.Create("b", 15, board.Freestyle)
+	expect(t, bob, MsgCreate)
+	carol.Create("c", 15, board.Renju)
+	expect(t, carol, MsgCreate)
+
+	// 同じidでは作れない
+	dave := register(t, addr, "dave", 0)
+	dave.Create("a", 9, board.Freestyle)
+	if m := expect(t, dave, MsgError); m.Error != ErrGameExists.Error() {
+		t.Fatalf("error = %q", m.Error)
+	}
+
+	list := func(size int, rule string) []string {
+		t.Helper()
+		dave.List(size, rule)
+		var ids []string
+		for _, g := range expect(t, dave, MsgGames).Games {
+			ids = append(ids, g.ID)
+		}
+		return ids
+	}
+	for _, tc := range []struct {
+		size int
+		rule string
+		want string
+	}{
+		{0, "", "a b c"},
+		{15, "", "b c"},
+		{0, "renju", "c"},
+		{15, "freestyle", "b"},
+		{13, "", ""},
+	} {
+		got := list(tc.size, tc.rule)
+		if s := strings.Join(got, " "); s != tc.want {
+			t.Errorf("List(%d, %q) = %q, want %q", tc.size, tc.rule, s, tc.want)
+		}
+	}
+}
+
+func TestCreateAndJoin(t *testing.T) {
+	_, addr := startServer(t)
+	alice := register(t, addr, "alice", 0)
+	bob := register(t, addr, "bob", 0)
+	alice.Create("", 13, board.Renju)
+	m := expect(t, alice, MsgCreate)
+	if m.Game == "" || m.Size != 13 || m.Rule != board.Renju.String() {
+		t.Fatalf("create = %+v", m)
+	}
+	// 対局を作ったら相手を待っている
+	alice.Create("", 13, board.Renju)
+	if e := expect(t, alice, MsgError); e.Error != ErrJoined.Error() {
+		t.Fatalf("error = %q", e.Error)
+	}
+
+	bob.Join("", m.Game, 13, board.Renju)
+	for _, c := range []*Client{alice, bob} {
+		st := expect(t, c, MsgStart)
+		if st.Game != m.Game || st.Black != "alice" || st.White != "bob" {
+			t.Fatalf("start = %+v", st)
+		}
+	}
+	// 始まった対局は待っている一覧に出ず、観戦の一覧に出る
+	bob.List(0, "")
+	if g := expect(t, bob, MsgGames).Games; len(g) != 0 {
+		t.Fatalf("waiting = %+v", g)
+	}
+	bob.send(Message{Type: MsgList, Status: StatusPlaying})
+	if g := expect(t, bob, MsgGames).Games; len(g) != 1 || !g[0].Started || g[0].ID != m.Game {
+		t.Fatalf("playing = %+v", g)
+	}
+}
+
+// 自分で決めたIDと自動で振るIDが重なっても、前の対局は残る
+func TestCreateIDCollision(t *testing.T) {
+	s, addr := startServer(t)
+	alice := register(t, addr, "alice", 0)
+	bob := register(t, addr, "bob", 0)
+	alice.Create("1", 9, board.Freestyle)
+	expect(t, alice, MsgCreate)
+	bob.Create("", 9, board.Freestyle)
+	if m := expect(t, bob, MsgCreate); m.Game == "1" || m.Game == "" {
+		t.Fatalf("auto id = %q", m.Game)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r := s.rooms["1"]; r == nil || r.names[0] != "alice" {
+		t.Fatalf("room 1 = %+v", r)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	s, addr := startServer(t)
+	alice := register(t, addr, "alice", 1500)
+	bob := register(t, addr, "bob", 1800)
+	carol := register(t, addr, "carol", 1650)
+
+	// 300離れていると初めの幅には入らない
+	alice.Match(9, board.Freestyle)
+	bob.Match(9, board.Freestyle)
+	// 大きさが違えば組まない
+	carol.Match(13, board.Freestyle)
+	waitFor(t, func() bool { return queued(s) == 3 })
+
+	// 30秒待ったことにすると幅が広がって組む
+	s.mu.Lock()
+	s.pair(time.Now().Add(31 * time.Second))
+	s.mu.Unlock()
+	for _, c := range []*Client{alice, bob} {
+		m := expect(t, c, MsgStart)
+		// レーティングの低い方が黒
+		if m.Black != "alice" || m.White != "bob" || m.Size != 9 {
+			t.Fatalf("start = %+v", m)
+		}
+	}
+	if n := queued(s); n != 1 {
+		t.Fatalf("%d still queued", n)
+	}
+
+	// 近い相手とはすぐに組み、やめたら列から外れる
+	dave := register(t, addr, "dave", 1600)
+	dave.Match(13, board.Freestyle)
+	for _, c := range []*Client{carol, dave} {
+		if m := expect(t, c, MsgStart); m.Black != "dave" || m.White != "carol" {
+			t.Fatalf("start = %+v", m)
+		}
+	}
+	erin := register(t, addr, "erin", 1500)
+	erin.Match(9, board.Freestyle)
+	waitFor(t, func() bool { return queued(s) == 1 })
+	erin.CancelMatch()
+	waitFor(t, func() bool { return queued(s) == 0 })
+}
+
+func queued(s *Server) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+func TestPresence(t *testing.T) {
+	_, addr := startServer(t)
+	alice := register(t, addr, "alice", 1500)
+	bob := register(t, addr, "bob", 1600)
+	presence := func(name string, status string) {
+		t.Helper()
+		for {
+			m := expect(t, alice, MsgPresence)
+			if m.Name == name && m.Status == status {
+				return
+			}
+		}
+	}
+	presence("bob", StatusIdle)
+
+	alice.Players()
+	players := expect(t, alice, MsgPlayers).Players
+	if len(players) != 2 || players[0].Name != "alice" || players[1].Name != "bob" || players[1].Rating != 1600 {
+		t.Fatalf("players = %+v", players)
+	}
+
+	bob.Match(9, board.Freestyle)
+	presence("bob", StatusWaiting)
+	bob.CancelMatch()
+	presence("bob", StatusIdle)
+	bob.Create("", 9, board.Freestyle)
+	presence("bob", StatusWaiting)
+	bob.Close()
+	presence("bob", StatusOffline)
+}
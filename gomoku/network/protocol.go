@@ -0,0 +1,272 @@
+// network は二台の端末で対局するためのサーバーとクライアント
+//
+// TCPの上で、一行に一つのJSON(Message)をやりとりする
+// 着手はサーバーがgame.Gameで確かめてから両方の対局者に送る
+package network
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"../board"
+	"../game"
+)
+
+// Message.Typeの値
+const (
+	MsgJoin    = "join"    // 対局に入る(クライアントから)
+	MsgStart   = "start"   // 相手がそろった(サーバーから)
+	MsgMove    = "move"    // 着手。サーバーは確かめてから両方に送り返す
+	MsgResign  = "resign"  // 投了
+	MsgDraw    = "draw"    // 引き分けの提案
+	MsgSwap    = "swap"    // 打ち始める前に黒と白の入れ替えを提案する
+	MsgAccept  = "accept"  // 相手の提案を受ける
+	MsgDecline = "decline" // 相手の提案を断る
+	MsgClaim   = "claim"   // 五を作れる所がなくなったので引き分けを申し出る
+	MsgAbort   = "abort"   // game.AbortMoves手より前なら対局をやめる
+	MsgChat    = "chat"
+	MsgSync    = "sync"   // 対局の状態を問い合わせる/知らせる
+	MsgResult  = "result" // 終局(サーバーから)
+	MsgClock   = "clock"  // 時計を止めた、動かした(サーバーから)
+	MsgLeave   = "leave"  // 相手が抜けた(サーバーから)
+	MsgResume  = "resume" // 切れた対局につなぎ直す。以後の手は手数の続きから送り直す
+	MsgWatch   = "watch"  // 観戦を始める。返事には始めからの手順が入る
+	MsgUnwatch = "unwatch"
+	MsgPing    = "ping" // 何もしないが、つながっていることを知らせる(クライアントから)
+	MsgError   = "error"
+)
+
+type Message struct {
+	Type   string   `json:"type"`
+	Game   string   `json:"game,omitempty"` // 対局のID
+	Name   string   `json:"name,omitempty"`
+	Color  int      `json:"color,omitempty"` // board.Black か board.White
+	Size   int      `json:"size,omitempty"`
+	Rule   string   `json:"rule,omitempty"`
+	Move   string   `json:"move,omitempty"`  // board.FormatPointの座標
+	Seq    int      `json:"seq,omitempty"`   // 何手目か
+	Start  string   `json:"start,omitempty"` // board.FormatPositionの開始局面
+	Moves  []string `json:"moves,omitempty"`
+	Black  string   `json:"black,omitempty"`
+	White  string   `json:"white,omitempty"`
+	Result string   `json:"result,omitempty"`
+	Text   string   `json:"text,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	Token  string   `json:"token,omitempty"` // つなぎ直すための合言葉
+	Time   string   `json:"time,omitempty"`  // game.TimeControlの表記
+	// 通信対局で手番の側が打つ期限
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// 黒、白の残り時間(時間はナノ秒)
+	Clock []game.PlayerTime `json:"clock,omitempty"`
+
+	// ロビー
+	Rating  float64      `json:"rating,omitempty"`
+	Bot     bool         `json:"bot,omitempty"` // エンジンが打つ対局者
+	Status  string       `json:"status,omitempty"`
+	Games   []GameInfo   `json:"games,omitempty"`
+	Players []PlayerInfo `json:"players,omitempty"`
+}
+
+// 書き込みが詰まった相手を待ち続けないようにする
+const writeTimeout = 10 * time.Second
+
+const (
+	// 一つのメッセージの大きさの上限。これより長い行を送る相手は切る
+	maxMessage = 1 << 20
+	// クライアントはこの間隔でpingを送り、サーバーはidleTimeoutの間なにも届かなければ切る
+	pingInterval = 30 * time.Second
+	idleTimeout  = 2 * time.Minute
+)
+
+var errSlowReader = errors.New("network: peer is not reading")
+
+// 一行一メッセージで読み書きする接続
+type Conn struct {
+	c   net.Conn
+	r   *bufio.Scanner
+	wmu sync.Mutex
+	// Queueを呼んでいれば、Sendはここに入れるだけで書き込みを待たない
+	out  chan []byte
+	done chan struct{}
+	once sync.Once
+	// 0でなければ、この間なにも届かないとReceiveがエラーを返す
+	idle time.Duration
+}
+
+func NewConn(c net.Conn) *Conn {
+	r := bufio.NewScanner(c)
+	r.Buffer(make([]byte, 4096), maxMessage)
+	return &Conn{c: c, r: r, done: make(chan struct{})}
+}
+
+// 読み込みにd以上待たされたら切る
+func (c *Conn) Idle(d time.Duration) {
+	c.idle = d
+}
+
+// 閉じるまでd毎にpingを送り、相手のIdleで切られないようにする
+func (c *Conn) KeepAlive(d time.Duration) {
+	go func() {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if err := c.Send(Message{Type: MsgPing}); err != nil {
+					return
+				}
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// 送るメッセージをn個まで溜める列を作り、別のgoroutineから書き込む
+// サーバーはロックを持ったまま送るので、読まない相手に全体が止められないようにする
+// 列があふれるほど読まない相手は切る
+func (c *Conn) Queue(n int) {
+	c.out = make(chan []byte, n)
+	go func() {
+		for {
+			select {
+			case data := <-c.out:
+				if err := c.write(data); err != nil {
+					c.Close()
+					return
+				}
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+func (c *Conn) Send(m Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if c.out == nil {
+		return c.write(data)
+	}
+	select {
+	case <-c.done:
+		return net.ErrClosed
+	case c.out <- data:
+		return nil
+	default:
+		c.Close()
+		return errSlowReader
+	}
+}
+
+func (c *Conn) write(data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.c.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.c.Write(data)
+	return err
+}
+
+func (c *Conn) Receive() (Message, error) {
+	var m Message
+	if c.idle > 0 {
+		c.c.SetReadDeadline(time.Now().Add(c.idle))
+	}
+	if !c.r.Scan() {
+		if err := c.r.Err(); err != nil {
+			return m, err
+		}
+		return m, io.EOF
+	}
+	err := json.Unmarshal(c.r.Bytes(), &m)
+	return m, err
+}
+
+func (c *Conn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.c.Close()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.c.RemoteAddr()
+}
+
+// 対局の今の状態をsyncメッセージにする
+func syncMessage(id string, g *game.Game) Message {
+	first := g.Turn
+	if len(g.Moves) > 0 {
+		first = g.Moves[0].Which
+	}
+	moves := make([]string, len(g.Moves))
+	for i, m := range g.Moves {
+		moves[i] = board.FormatPoint(board.Point{X: m.X, Y: m.Y})
+	}
+	m := Message{
+		Type:   MsgSync,
+		Game:   id,
+		Size:   g.Size,
+		Rule:   g.Rule.String(),
+		Start:  board.FormatPosition(g.Start, first, g.Rule),
+		Moves:  moves,
+		Seq:    len(g.Moves),
+		Result: g.Result,
+		Clock:  timesOf(g),
+	}
+	if g.Clock != nil {
+		m.Time = g.Clock.Control.String()
+	}
+	return m
+}
+
+func timesOf(g *game.Game) []game.PlayerTime {
+	if g.Clock == nil {
+		return nil
+	}
+	return []game.PlayerTime{g.Clock.Remaining(board.Black), g.Clock.Remaining(board.White)}
+}
+
+func moveMessage(id string, m board.Move, seq int) Message {
+	return Message{
+		Type:  MsgMove,
+		Game:  id,
+		Move:  board.FormatPoint(board.Point{X: m.X, Y: m.Y}),
+		Color: m.Which,
+		Seq:   seq,
+	}
+}
+
+// start, syncメッセージから対局を並べ直す
+func GameOf(m Message) (*game.Game, error) {
+	start, toMove, rule, err := board.ParsePosition(m.Start)
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]board.Move, len(m.Moves))
+	which := toMove
+	for i, v := range m.Moves {
+		p, err := board.ParsePoint(v)
+		if err != nil {
+			return nil, err
+		}
+		moves[i] = board.Move{X: p.X, Y: p.Y, Which: which}
+		which = board.Opponent(which)
+	}
+	g, err := game.Replay(start, toMove, moves)
+	if err != nil {
+		return nil, err
+	}
+	g.Rule = rule
+	if m.Result != "" {
+		g.Result = m.Result
+		g.Ending = game.EndingOf(m.Result)
+	}
+	return g, nil
+}
@@ -0,0 +1,76 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"../board"
+)
+
+var ErrBadToken = errors.New("network: bad session token")
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// 合言葉で席に戻る
+// 席に戻れるのはこの道だけで、名前を登録しても戻らない
+// Seqまでの手は持っているものとして、その続きの手を送り直す
+// Seqがおかしければ始めからの手順を送る
+func (s *Server) resume(cl *client, m Message) error {
+	if cl.room != nil {
+		return ErrJoined
+	}
+	r := s.rooms[m.Game]
+	if r == nil {
+		return ErrNoGame
+	}
+	i := -1
+	for j, t := range r.tokens {
+		if m.Token != "" && t == m.Token {
+			i = j
+		}
+	}
+	if i < 0 {
+		return ErrBadToken
+	}
+	// 古い接続が残っていれば置き換える
+	if old := r.players[i]; old != nil {
+		old.room = nil
+		old.conn.Close()
+	}
+	s.removeFromQueue(cl)
+	s.unwatch(cl)
+	if !cl.registered {
+		cl.name = r.names[i]
+	}
+	cl.room, cl.color = r, i+1
+	r.players[i] = cl
+
+	g := r.game
+	if m.Seq < 0 || m.Seq > len(g.Moves) {
+		ack := s.syncFor(r, cl)
+		ack.Type = MsgResume
+		cl.conn.Send(ack)
+	} else {
+		cl.conn.Send(Message{Type: MsgResume, Game: r.id, Color: cl.color, Seq: len(g.Moves)})
+		for n := m.Seq; n < len(g.Moves); n++ {
+			cl.conn.Send(moveMessage(r.id, g.Moves[n], n+1))
+		}
+	}
+	if opp := r.player(board.Opponent(cl.color)); opp != nil {
+		opp.conn.Send(Message{Type: MsgResume, Game: r.id, Name: cl.name, Color: cl.color})
+	}
+	if cl.registered {
+		s.setStatus(cl, StatusPlaying)
+	}
+	if r.started() {
+		s.resumeClock(r)
+	}
+	return nil
+}
@@ -0,0 +1,558 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"../board"
+	"../game"
+	"../rating"
+	"../record"
+)
+
+var (
+	ErrNotJoined   = errors.New("network: not in a game")
+	ErrJoined      = errors.New("network: already in a game")
+	ErrGameFull    = errors.New("network: game is full")
+	ErrNoGame      = errors.New("network: no such game")
+	ErrNotStarted  = errors.New("network: game has not started")
+	ErrNotYourTurn = errors.New("network: not your turn")
+	ErrBadSize     = errors.New("network: bad board size")
+	ErrUnknownType = errors.New("network: unknown message type")
+	errNameTooLong = errors.New("network: name is too long")
+	errChatTooLong = errors.New("network: chat is too long")
+)
+
+const (
+	DefaultSize   = 15
+	DefaultPort   = "7650"
+	DefaultGrace  = time.Minute
+	maxNameLength = 32
+	maxChatLength = 500
+	// 対局者ごとに溜めておける送るメッセージの数
+	sendQueue = 256
+)
+
+// 対局一つ分
+type room struct {
+	id       string
+	game     *game.Game
+	players  [2]*client // 黒、白の順。抜けている間はnil
+	names    [2]string
+	settings settings
+	begun    bool // 一度でも二人そろったか
+	watchers map[*client]bool
+	tokens   [2]string // つなぎ直すときに席を確かめる合言葉
+	tick     int       // 時間切れの確かめを仕掛けた回数(古いものを無視するため)
+	gone     [2]int    // 切れた回数(古い待ち時間切れを無視するため)
+}
+
+func (r *room) started() bool {
+	return r.players[0] != nil && r.players[1] != nil
+}
+
+func (r *room) player(which int) *client {
+	return r.players[which-1]
+}
+
+// 対局者と観戦者に送る
+func (r *room) broadcast(m Message) {
+	for _, c := range r.players {
+		if c != nil {
+			c.conn.Send(m)
+		}
+	}
+	for c := range r.watchers {
+		c.conn.Send(m)
+	}
+}
+
+func (r *room) sync() Message {
+	m := syncMessage(r.id, r.game)
+	m.Black = r.names[0]
+	m.White = r.names[1]
+	return m
+}
+
+func (r *room) info() GameInfo {
+	return GameInfo{
+		ID:       r.id,
+		Size:     r.game.Size,
+		Rule:     r.game.Rule.String(),
+		Black:    r.names[0],
+		White:    r.names[1],
+		Moves:    len(r.game.Moves),
+		Started:  r.begun,
+		Result:   r.game.Result,
+		Time:     r.settings.time,
+		Watchers: len(r.watchers),
+		Bot:      r.hasBot(),
+	}
+}
+
+// 接続してきた対局者
+type client struct {
+	conn       *Conn
+	name       string
+	registered bool // ロビーに名前を登録したか
+	bot        bool // エンジンが打つ対局者として登録したか
+	rating     float64
+	status     string
+	room       *room
+	color      int
+	watching   *room // 観戦している対局
+}
+
+// 対局を仲介するサーバー
+// 盤の状態はサーバーのgame.Gameが正しいものとする
+type Server struct {
+	// 設定されていれば登録した対局者のレーティングを使い、終局ごとに更新する
+	Ratings *rating.Registry
+	// 空でなければ終局ごとにRatingsを保存する
+	RatingsFile string
+	// 対局中に切れたとき、つなぎ直すのを待つ時間(0なら待たずに負け)
+	Grace time.Duration
+	// 切れている間の時計の扱い
+	Clocks ClockPolicy
+	// 自動対局でレーティング差をどこまで許すか(待つほど広げる)
+	MatchRange float64
+	// 通信対局で一手にかけられる時間(0ならDefaultMoveDeadline)
+	MoveDeadline time.Duration
+
+	mu      sync.Mutex
+	rooms   map[string]*room
+	players map[string]*client // ロビーにいる対局者
+	queue   []*matchRequest
+	nextID  int
+	once    sync.Once
+	corrDir string
+	corr    map[string]*corrGame // OpenCorrespondenceを呼ぶまではnil
+}
+
+func NewServer() *Server {
+	return &Server{
+		Grace:      DefaultGrace,
+		MatchRange: DefaultMatchRange,
+		rooms:      map[string]*room{},
+		players:    map[string]*client{},
+	}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	s.once.Do(func() { go s.matchLoop() })
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		conn := NewConn(c)
+		conn.Queue(sendQueue)
+		conn.Idle(idleTimeout)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn *Conn) {
+	cl := &client{conn: conn}
+	defer conn.Close()
+	defer s.leave(cl)
+	for {
+		m, err := conn.Receive()
+		if err != nil {
+			// 壊れたJSONなら知らせて続ける
+			var se *json.SyntaxError
+			var te *json.UnmarshalTypeError
+			if errors.As(err, &se) || errors.As(err, &te) {
+				conn.Send(Message{Type: MsgError, Error: err.Error()})
+				continue
+			}
+			return
+		}
+		if err := s.dispatch(cl, m); err != nil {
+			conn.Send(Message{Type: MsgError, Error: err.Error()})
+		}
+	}
+}
+
+func (s *Server) dispatch(cl *client, m Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch m.Type {
+	case MsgPing:
+		return nil
+	case MsgJoin:
+		return s.join(cl, m)
+	case MsgRegister:
+		return s.register(cl, m)
+	case MsgList:
+		return s.list(cl, m)
+	case MsgPlayers:
+		return s.listPlayers(cl)
+	case MsgCreate:
+		return s.create(cl, m)
+	case MsgMatch:
+		return s.match(cl, m)
+	case MsgChallenge:
+		return s.challenge(cl, m)
+	case MsgCancel:
+		return s.cancelMatch(cl)
+	case MsgResume:
+		return s.resume(cl, m)
+	case MsgWatch:
+		return s.watch(cl, m)
+	case MsgUnwatch:
+		s.unwatch(cl)
+		return nil
+	case MsgCorrespond:
+		return s.correspond(cl, m)
+	case MsgPost:
+		return s.post(cl, m)
+	case MsgFetch:
+		return s.fetch(cl, m)
+	case MsgMyGames:
+		return s.myGames(cl)
+	case MsgResign:
+		// 部屋にいないときにGameを指定すれば通信対局の投了
+		if cl.room == nil && m.Game != "" {
+			return s.corrResign(cl, m)
+		}
+	}
+
+	r := cl.room
+	if r == nil {
+		return ErrNotJoined
+	}
+	if !r.begun {
+		return ErrNotStarted
+	}
+	g := r.game
+	opp := r.player(board.Opponent(cl.color))
+
+	switch m.Type {
+	case MsgMove:
+		if g.Over() {
+			return game.ErrGameOver
+		}
+		if g.Turn != cl.color {
+			return ErrNotYourTurn
+		}
+		p, err := board.ParsePoint(m.Move)
+		if err != nil {
+			return err
+		}
+		if err := g.Play(p.X, p.Y); err != nil {
+			// 打つ前に時間が切れていた
+			if g.Over() {
+				s.finish(r, cl.color)
+				return nil
+			}
+			return err
+		}
+		mv := moveMessage(r.id, g.Moves[len(g.Moves)-1], len(g.Moves))
+		mv.Clock = timesOf(g)
+		r.broadcast(mv)
+		if g.Over() {
+			s.finish(r, 0)
+		} else {
+			s.armClock(r)
+		}
+	case MsgResign:
+		if err := g.Resign(cl.color); err != nil {
+			return err
+		}
+		s.finish(r, cl.color)
+	case MsgAbort:
+		if err := g.Abort(cl.color); err != nil {
+			return err
+		}
+		s.finish(r, 0)
+	case MsgClaim:
+		if err := g.ClaimDraw(cl.color); err != nil {
+			return err
+		}
+		s.finish(r, 0)
+	case MsgDraw, MsgSwap:
+		offer := g.OfferDraw
+		if m.Type == MsgSwap {
+			offer = g.OfferSwap
+		}
+		if err := offer(cl.color); err != nil {
+			return err
+		}
+		if opp != nil {
+			opp.conn.Send(Message{Type: m.Type, Game: r.id, Color: cl.color})
+		}
+	case MsgAccept:
+		o, err := g.Accept(cl.color)
+		if err != nil {
+			return err
+		}
+		if o.Kind == game.SwapOffer {
+			s.swap(r)
+		} else {
+			s.finish(r, 0)
+		}
+	case MsgDecline:
+		if _, err := g.Decline(cl.color); err != nil {
+			return err
+		}
+		if opp != nil {
+			opp.conn.Send(Message{Type: MsgDecline, Game: r.id, Color: cl.color})
+		}
+	case MsgChat:
+		if len(m.Text) > maxChatLength {
+			return errChatTooLong
+		}
+		r.broadcast(Message{Type: MsgChat, Game: r.id, Name: cl.name, Color: cl.color, Text: m.Text})
+	case MsgSync:
+		cl.conn.Send(s.syncFor(r, cl))
+	default:
+		return ErrUnknownType
+	}
+	return nil
+}
+
+// 終局を知らせて対局を片付け、レーティングに反映する
+// colorは投了や放棄で負けた側
+func (s *Server) finish(r *room, color int) {
+	g := r.game
+	r.broadcast(Message{Type: MsgResult, Game: r.id, Result: g.Result, Color: color, Text: g.Ending.String()})
+	log.Printf("network: game %s ended: %s (%s)", r.id, g.Result, g.Ending)
+	// やめた対局はレーティングに入れない
+	if s.Ratings != nil && r.names[0] != "" && r.names[1] != "" && g.Result != record.Void {
+		if err := s.Ratings.Record(r.names[0], r.names[1], g.Result, len(g.Moves)); err != nil {
+			log.Println(err)
+		} else if s.RatingsFile != "" {
+			if err := s.Ratings.Save(s.RatingsFile); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+	for i, c := range r.players {
+		if c == nil {
+			continue
+		}
+		c.room = nil
+		r.players[i] = nil
+		if !c.registered {
+			continue
+		}
+		if s.Ratings != nil {
+			c.rating = s.Ratings.Player(c.name).Elo
+		}
+		s.setStatus(c, StatusIdle)
+	}
+	for c := range r.watchers {
+		c.watching = nil
+	}
+	r.watchers = nil
+	delete(s.rooms, r.id)
+}
+
+func (s *Server) syncFor(r *room, cl *client) Message {
+	m := r.sync()
+	m.Color = cl.color
+	m.Token = r.tokens[cl.color-1]
+	return m
+}
+
+// 黒と白を入れ替えて、対局者と観戦者に知らせ直す
+func (s *Server) swap(r *room) {
+	r.players[0], r.players[1] = r.players[1], r.players[0]
+	r.names[0], r.names[1] = r.names[1], r.names[0]
+	r.tokens[0], r.tokens[1] = r.tokens[1], r.tokens[0]
+	r.gone[0], r.gone[1] = r.gone[1], r.gone[0]
+	for i, c := range r.players {
+		if c != nil {
+			c.color = i + 1
+			c.conn.Send(s.syncFor(r, c))
+		}
+	}
+	for c := range r.watchers {
+		c.conn.Send(r.sync())
+	}
+	log.Printf("network: game %s swapped: %s - %s", r.id, r.names[0], r.names[1])
+}
+
+func (s *Server) newRoom(id string, st settings) *room {
+	// 自分でIDを決めた対局と重ならない番号まで進める
+	for id == "" || s.rooms[id] != nil {
+		s.nextID++
+		id = strconv.Itoa(s.nextID)
+	}
+	g := game.New(st.size)
+	g.Rule = st.rule
+	if st.time != "" {
+		tc, _ := game.ParseTimeControl(st.time)
+		g.Clock = game.NewClock(tc)
+	}
+	r := &room{id: id, game: g, settings: st, watchers: map[*client]bool{}}
+	s.rooms[id] = r
+	return r
+}
+
+// IDを指定すればその対局に入り(なければ作る)、
+// 指定しなければ同じ大きさ、ルール、持ち時間で相手を待っている対局に入る
+func (s *Server) join(cl *client, m Message) error {
+	if cl.room != nil {
+		return ErrJoined
+	}
+	if err := s.checkName(cl, m.Name); err != nil {
+		return err
+	}
+	if !cl.registered {
+		cl.name = m.Name
+	}
+	st, err := gameSettings(m)
+	if err != nil {
+		return err
+	}
+
+	r := s.rooms[m.Game]
+	if m.Game == "" {
+		r = nil
+		for _, w := range s.rooms {
+			if !w.begun && w.settings == st {
+				r = w
+				break
+			}
+		}
+	}
+	if r == nil {
+		r = s.newRoom(m.Game, st)
+	}
+	if r.begun {
+		return ErrGameFull
+	}
+	s.seat(cl, r)
+	return nil
+}
+
+// 対局を作るときの条件
+type settings struct {
+	size int
+	rule board.Rule
+	time string // game.TimeControlの表記。空なら持ち時間なし
+}
+
+func gameSettings(m Message) (settings, error) {
+	st := settings{size: m.Size, rule: board.Freestyle}
+	if st.size == 0 {
+		st.size = DefaultSize
+	}
+	if st.size < 5 || st.size > 26 {
+		return st, ErrBadSize
+	}
+	if m.Rule != "" {
+		var err error
+		if st.rule, err = board.ParseRule(m.Rule); err != nil {
+			return st, err
+		}
+	}
+	if m.Time != "" {
+		tc, err := game.ParseTimeControl(m.Time)
+		if err != nil {
+			return st, err
+		}
+		// 同じ持ち時間を同じ表記で比べられるようにする
+		st.time = tc.String()
+	}
+	return st, nil
+}
+
+// 空いている席に着かせ、二人そろったら対局を始める
+// 先に入った方が黒
+func (s *Server) seat(cl *client, r *room) {
+	s.removeFromQueue(cl)
+	s.unwatch(cl)
+	cl.color = board.Black
+	if r.players[0] != nil {
+		cl.color = board.White
+	}
+	cl.room = r
+	r.players[cl.color-1] = cl
+	r.names[cl.color-1] = cl.name
+	if !r.started() {
+		if cl.registered {
+			s.setStatus(cl, StatusWaiting)
+		}
+		return
+	}
+	r.begun = true
+	r.tokens = [2]string{newToken(), newToken()}
+	r.game.StartClock()
+	s.armClock(r)
+	for _, c := range r.players {
+		start := s.syncFor(r, c)
+		start.Type = MsgStart
+		c.conn.Send(start)
+		if c.registered {
+			s.setStatus(c, StatusPlaying)
+		}
+	}
+	for c := range r.watchers {
+		c.conn.Send(r.sync())
+	}
+	log.Printf("network: game %s started: %s - %s", r.id, r.names[0], r.names[1])
+}
+
+// 接続が切れたら対局から外し、相手に知らせる
+// 対局中なら席を残してGraceだけ待ち、戻らなければ負けにする
+func (s *Server) leave(cl *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeFromQueue(cl)
+	s.unwatch(cl)
+	if cl.registered && s.players[cl.name] == cl {
+		delete(s.players, cl.name)
+		s.presence(cl, StatusOffline)
+	}
+	r := cl.room
+	if r == nil || r.players[cl.color-1] != cl {
+		return
+	}
+	r.players[cl.color-1] = nil
+	cl.room = nil
+	r.game.Offer = game.Offer{}
+	r.broadcast(Message{Type: MsgLeave, Game: r.id, Name: cl.name, Color: cl.color})
+	switch {
+	case !r.begun:
+		delete(s.rooms, r.id)
+	case s.Grace > 0:
+		if s.Clocks == ClockPause {
+			s.pauseClock(r)
+		}
+		i := cl.color - 1
+		r.gone[i]++
+		gone := r.gone[i]
+		time.AfterFunc(s.Grace, func() { s.expire(r, cl.color, gone) })
+	default:
+		r.game.Forfeit(cl.color)
+		s.finish(r, cl.color)
+	}
+}
+
+// 待っても戻らなければ負けにする
+func (s *Server) expire(r *room, color int, gone int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.players[color-1] != nil || r.gone[color-1] != gone || r.game.Over() {
+		return
+	}
+	r.game.Forfeit(color)
+	s.finish(r, color)
+}
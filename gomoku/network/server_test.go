@@ -0,0 +1,265 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"../board"
+	"../record"
+)
+
+// localhostでサーバーを動かす
+func startServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer()
+	go s.Serve(l)
+	t.Cleanup(func() { l.Close() })
+	return s, l.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *Client {
+	t.Helper()
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// typesのどれかが届くまで読み飛ばす
+func expect(t *testing.T, c *Client, types ...string) Message {
+	t.Helper()
+	type result struct {
+		m   Message
+		err error
+	}
+	for {
+		ch := make(chan result, 1)
+		go func() {
+			m, err := c.Next()
+			ch <- result{m, err}
+		}()
+		var r result
+		select {
+		case r = <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v", types)
+		}
+		if r.err != nil && r.err != ErrResync {
+			t.Fatalf("waiting for %v: %v", types, r.err)
+		}
+		for _, typ := range types {
+			if r.m.Type == typ {
+				return r.m
+			}
+		}
+	}
+}
+
+// 二人を同じ対局に入れる(aが黒)
+func startGame(t *testing.T, addr string, size int) (*Client, *Client) {
+	t.Helper()
+	a := dial(t, addr)
+	b := dial(t, addr)
+	if err := a.Join("alice", "g1", size, board.Freestyle); err != nil {
+		t.Fatal(err)
+	}
+	// 先に入った方が黒になるように、席に着いたのを確かめてから入る
+	waitFor(t, func() bool { return seated(addr) })
+	if err := b.Join("bob", "g1", size, board.Freestyle); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, a, MsgStart)
+	expect(t, b, MsgStart)
+	return a, b
+}
+
+// 席に着いた対局があるか、観戦の一覧で確かめる
+func seated(addr string) bool {
+	c, err := Dial(addr)
+	if err != nil {
+		return false
+	}
+	defer c.Close()
+	c.List(0, "")
+	m, err := c.Next()
+	return err == nil && len(m.Games) > 0
+}
+
+func waitFor(t *testing.T, ok func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if ok() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("condition not met")
+}
+
+func play(t *testing.T, c *Client, move string) {
+	t.Helper()
+	p, err := board.ParsePoint(move)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Play(p.X, p.Y); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJoinAndMove(t *testing.T) {
+	_, addr := startServer(t)
+	a, b := startGame(t, addr, 9)
+	if a.Color != board.Black || b.Color != board.White {
+		t.Fatalf("colors = %d, %d", a.Color, b.Color)
+	}
+	if a.Black != "alice" || a.White != "bob" {
+		t.Fatalf("names = %q, %q", a.Black, a.White)
+	}
+
+	play(t, a, "e5")
+	for _, c := range []*Client{a, b} {
+		m := expect(t, c, MsgMove)
+		if m.Move != "e5" || m.Seq != 1 || m.Color != board.Black {
+			t.Fatalf("move = %+v", m)
+		}
+	}
+
+	// 手番でない側、埋まっている点、盤の外は断られる
+	play(t, a, "d4")
+	if m := expect(t, a, MsgError); m.Error != ErrNotYourTurn.Error() {
+		t.Fatalf("error = %q", m.Error)
+	}
+	play(t, b, "e5")
+	if m := expect(t, b, MsgError); m.Error == "" {
+		t.Fatal("occupied point accepted")
+	}
+	b.send(Message{Type: MsgMove, Move: "z99"})
+	expect(t, b, MsgError)
+	if n := len(b.Game.Moves); n != 1 {
+		t.Fatalf("client has %d moves", n)
+	}
+}
+
+func TestFiveWins(t *testing.T) {
+	_, addr := startServer(t)
+	a, b := startGame(t, addr, 9)
+	moves := []string{"a1", "a9", "b1", "b9", "c1", "c9", "d1", "d9", "e1"}
+	for i, mv := range moves {
+		c := a
+		if i%2 == 1 {
+			c = b
+		}
+		play(t, c, mv)
+		expect(t, a, MsgMove)
+		expect(t, b, MsgMove)
+	}
+	m := expect(t, b, MsgResult)
+	if m.Result != record.BlackWin || !b.Game.Over() {
+		t.Fatalf("result = %q", m.Result)
+	}
+}
+
+func TestResign(t *testing.T) {
+	_, addr := startServer(t)
+	a, b := startGame(t, addr, 9)
+	if err := b.Resign(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*Client{a, b} {
+		m := expect(t, c, MsgResult)
+		if m.Result != record.BlackWin+"R" || m.Color != board.White {
+			t.Fatalf("result = %+v", m)
+		}
+	}
+	// 終局したら部屋はなくなる
+	play(t, a, "e5")
+	if m := expect(t, a, MsgError); m.Error != ErrNotJoined.Error() {
+		t.Fatalf("error = %q", m.Error)
+	}
+}
+
+func TestDrawOffer(t *testing.T) {
+	_, addr := startServer(t)
+	a, b := startGame(t, addr, 9)
+
+	// 提案がなければ受けられない
+	b.Accept()
+	expect(t, b, MsgError)
+
+	a.OfferDraw()
+	if m := expect(t, b, MsgDraw); m.Color != board.Black {
+		t.Fatalf("offer = %+v", m)
+	}
+	b.Decline()
+	expect(t, a, MsgDecline)
+
+	a.OfferDraw()
+	expect(t, b, MsgDraw)
+	b.Accept()
+	for _, c := range []*Client{a, b} {
+		if m := expect(t, c, MsgResult); m.Result != record.Draw {
+			t.Fatalf("result = %q", m.Result)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	_, addr := startServer(t)
+	a, b := startGame(t, addr, 9)
+	play(t, a, "e5")
+	expect(t, b, MsgMove)
+	play(t, b, "d4")
+	expect(t, b, MsgMove)
+	b.Sync()
+	m := expect(t, b, MsgSync)
+	if len(m.Moves) != 2 || m.Moves[0] != "e5" || m.Moves[1] != "d4" || m.Color != board.White {
+		t.Fatalf("sync = %+v", m)
+	}
+	if b.Game.Turn != board.Black || len(b.Game.Moves) != 2 {
+		t.Fatalf("client game not rebuilt: %d moves, turn %d", len(b.Game.Moves), b.Game.Turn)
+	}
+}
+
+func TestOutOfSequenceMove(t *testing.T) {
+	_, addr := startServer(t)
+	a, b := startGame(t, addr, 9)
+	play(t, a, "e5")
+	expect(t, b, MsgMove)
+	// 一手飛ばした手が届いたら反映せずに問い合わせ直す
+	b.Game.Moves = b.Game.Moves[:0]
+	play(t, b, "d4")
+	m, err := b.Next()
+	if err != ErrResync || m.Type != MsgMove {
+		t.Fatalf("Next = %v, %v", m.Type, err)
+	}
+	expect(t, b, MsgSync)
+	if len(b.Game.Moves) != 2 {
+		t.Fatalf("client has %d moves after sync", len(b.Game.Moves))
+	}
+}
+
+// 長すぎる行を送る相手は切る
+func TestMessageTooLong(t *testing.T) {
+	_, addr := startServer(t)
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	go c.Write(make([]byte, maxMessage+1))
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var buf [1]byte
+	if _, err := c.Read(buf[:]); err == nil {
+		t.Fatal("connection still open")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("server kept reading an oversized message")
+	}
+}
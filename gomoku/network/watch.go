@@ -0,0 +1,26 @@
+package network
+
+// 観戦を始め、これまでの手順を送る
+// 以後の着手、チャット、終局は対局者と同じように届く
+func (s *Server) watch(cl *client, m Message) error {
+	if cl.room != nil {
+		return ErrJoined
+	}
+	r := s.rooms[m.Game]
+	if r == nil {
+		return ErrNoGame
+	}
+	s.unwatch(cl)
+	r.watchers[cl] = true
+	cl.watching = r
+	w := r.sync()
+	w.Type = MsgWatch
+	return cl.conn.Send(w)
+}
+
+func (s *Server) unwatch(cl *client) {
+	if r := cl.watching; r != nil {
+		delete(r.watchers, cl)
+		cl.watching = nil
+	}
+}
@@ -0,0 +1,317 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"./board"
+	"./network"
+
+	"golang.org/x/mobile/app"
+	"golang.org/x/mobile/event/size"
+	"golang.org/x/mobile/exp/sprite"
+	"golang.org/x/mobile/exp/sprite/clock"
+	"golang.org/x/mobile/gl"
+)
+
+// ネットワーク対局の受信をイベントループに渡す
+type netEvent struct {
+	m network.Message
+}
+
+var (
+	netClient *network.Client
+	netHost   *network.LANHost // LANに対局を開いていれば、その手元のサーバー
+	netColor  int
+	netBlack  string
+	netWhite  string
+
+	// アプリが裏に回っている間に届いたメッセージ
+	netPending []network.Message
+)
+
+// GOMOKU_SERVERにサーバーのアドレスがあればつないで相手を待つ
+// GOMOKU_WATCHがあればその対局を観戦する
+// GOMOKU_GAMEがあればその対局に入り、なければGOMOKU_NAMEでロビーに登録して
+// レーティングの近い相手を探してもらう
+// GOMOKU_BOTがあれば、GOMOKU_NAMEで登録してそのボットに対局を申し込む
+// GOMOKU_LANがhostならLANに対局を開き、joinならLANで開いている対局を探して入る
+func startNetwork(a app.App) {
+	addr, lan := os.Getenv("GOMOKU_SERVER"), os.Getenv("GOMOKU_LAN")
+	name, id := os.Getenv("GOMOKU_NAME"), os.Getenv("GOMOKU_GAME")
+	var (
+		c   *network.Client
+		err error
+	)
+	switch {
+	case lan == "host":
+		// 手元のサーバーとはつなぎ直さない
+		addr = ""
+		c, netHost, err = network.HostLAN(network.DefaultGroup, name, 13, board.Freestyle, os.Getenv("GOMOKU_TIME"))
+	case lan != "":
+		c, addr, err = joinLAN(name)
+	case addr != "":
+		c, err = dialServer(addr, name, id)
+	default:
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	netClient = c
+	host := netHost
+	go func() {
+		for {
+			m, err := c.Next()
+			if err != nil {
+				log.Println(err)
+				if m.Type == "" {
+					if reconnect(c, addr) {
+						continue
+					}
+					if host != nil {
+						host.Close()
+					}
+					a.Send(netEvent{network.Message{Type: network.MsgLeave}})
+					return
+				}
+				continue
+			}
+			a.Send(netEvent{m})
+		}
+	}()
+}
+
+// 裏から戻ったら、onStartで消えた対局を戻してネットワーク対局を続ける
+// 裏にいた間に届いたメッセージを並べてから、サーバーに局面を問い合わせ直す
+func resumeNetwork(glctx gl.Context, sz size.Event) {
+	prevMode, prevB, prevStart, prevMoves := mode, b, startBoard, moves
+	prevTurn, prevEnd, prevClock, prevResult := whichTurn, endFlag, gameClock, endResult
+	onStart(glctx, sz)
+	mode, b, startBoard, moves = prevMode, prevB, prevStart, prevMoves
+	whichTurn, endFlag, gameClock, endResult = prevTurn, prevEnd, prevClock, prevResult
+	drawStones(b, sz)
+	requestEval(b, whichTurn)
+
+	pending := netPending
+	netPending = nil
+	for _, m := range pending {
+		onNetMessage(glctx, sz, m)
+	}
+	// 終局していればnetClientは閉じてある
+	if netClient != nil && mode == modeNetwork {
+		if err := netClient.Sync(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func dialServer(addr string, name string, id string) (*network.Client, error) {
+	c, err := network.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	c.Time = os.Getenv("GOMOKU_TIME")
+	if w := os.Getenv("GOMOKU_WATCH"); w != "" {
+		err = c.Watch(w)
+	} else if bot := os.Getenv("GOMOKU_BOT"); bot != "" && name != "" {
+		err = c.Register(name, 0)
+		if err == nil {
+			err = c.Challenge(bot, 13, board.Freestyle, board.Black)
+		}
+	} else if name != "" && id == "" {
+		err = c.Register(name, 0)
+		if err == nil {
+			err = c.Match(13, board.Freestyle)
+		}
+	} else {
+		err = c.Join(name, id, 13, board.Freestyle)
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// LANで開いている13路の対局を探して入る
+const lanWait = 5 * time.Second
+
+func joinLAN(name string) (*network.Client, string, error) {
+	found, err := network.Discover(network.DefaultGroup, lanWait)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, an := range found {
+		if an.Size != 13 {
+			continue
+		}
+		log.Printf("network: joining %s on %s", an.Name, an.Addr)
+		c, err := network.JoinLAN(an, name)
+		return c, an.Addr, err
+	}
+	return nil, "", network.ErrNotFound
+}
+
+// 対局中に切れたら、サーバーが席を残している間つなぎ直してみる
+const (
+	reconnectTries = 20
+	reconnectWait  = 3 * time.Second
+)
+
+func reconnect(c *network.Client, addr string) bool {
+	if addr == "" || c.Game == nil || c.Game.Over() || c.Token == "" {
+		return false
+	}
+	for i := 0; i < reconnectTries; i++ {
+		time.Sleep(reconnectWait)
+		err := c.Reconnect(addr)
+		if err == nil {
+			return true
+		}
+		log.Println("network: reconnect:", err)
+	}
+	return false
+}
+
+func onNetMessage(glctx gl.Context, sz size.Event, m network.Message) {
+	if m.Type == network.MsgResume && m.Start == "" {
+		// 続きの手は後から一手ずつ届く
+		log.Printf("network: %s reconnected", m.Name)
+		return
+	}
+	switch m.Type {
+	case network.MsgStart, network.MsgSync, network.MsgWatch, network.MsgResume:
+		g, err := network.GameOf(m)
+		if err != nil || g.Size != 13 {
+			log.Println("network:", err)
+			return
+		}
+		watching := mode == modeWatch || m.Type == network.MsgWatch
+		onStart(glctx, sz)
+		mode = modeNetwork
+		if watching {
+			mode = modeWatch
+		}
+		netColor, netBlack, netWhite = m.Color, m.Black, m.White
+		gameClock, err = network.ClockOf(m)
+		if err != nil {
+			log.Println("network:", err)
+		}
+		network.SetClock(gameClock, m, g.Turn)
+		b = g.Board
+		startBoard = g.Start
+		moves = g.Moves
+		whichTurn = g.Turn
+		endFlag = g.Over()
+		drawStones(b, sz)
+		requestEval(b, whichTurn)
+		log.Printf("network: %s - %s", netBlack, netWhite)
+	case network.MsgMove:
+		if !netMode() {
+			return
+		}
+		p, err := board.ParsePoint(m.Move)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		board.Set(b, p.X, p.Y, m.Color)
+		addStone(sz, p.X, p.Y, m.Color)
+		stoneNodes[len(stoneNodes)-1].Arranger = &dropIn{sz: sz, x: p.X, y: p.Y, start: -1}
+		moves = append(moves, board.Move{X: p.X, Y: p.Y, Which: m.Color})
+		updateThreats(b, sz)
+		whichTurn = board.Opponent(m.Color)
+		requestEval(b, whichTurn)
+		network.SetClock(gameClock, m, whichTurn)
+	case network.MsgClock:
+		if netMode() {
+			network.SetClock(gameClock, m, whichTurn)
+		}
+	case network.MsgResult:
+		if !netMode() {
+			return
+		}
+		// 終局したら接続を閉じ、棋譜を保存して検討できるようにする
+		endFlag = true
+		endResult = m.Result
+		endText = m.Text
+		if gameClock != nil {
+			gameClock.Pause()
+		}
+		analyzeGame()
+		netClient.Close()
+		netClient = nil
+		// 相手がつなぎ直してくることはもうないので、開いた対局も閉じる
+		if netHost != nil {
+			netHost.Close()
+			netHost = nil
+		}
+		log.Printf("network: result %s (%s)", m.Result, m.Text)
+	case network.MsgDraw:
+		log.Println("network: draw offered")
+	case network.MsgSwap:
+		log.Println("network: color swap offered")
+	case network.MsgDecline:
+		log.Println("network: offer declined")
+	case network.MsgChat:
+		log.Printf("network: %s: %s", m.Name, m.Text)
+	case network.MsgLeave:
+		log.Println("network: opponent left")
+	case network.MsgPresence:
+		if m.Bot {
+			log.Printf("network: bot %s is %s", m.Name, m.Status)
+		} else {
+			log.Printf("network: %s is %s", m.Name, m.Status)
+		}
+	case network.MsgError:
+		log.Println("network:", m.Error)
+	}
+}
+
+// 自分の手番なら着手をサーバーに送る
+// 盤に置くのはサーバーから着手が返ってきてから
+func sendNetMove(x int, y int) {
+	if netClient == nil || whichTurn != netColor || board.At(b, x, y) != board.Space {
+		return
+	}
+	if err := netClient.Play(x, y); err != nil {
+		log.Println(err)
+	}
+}
+
+func netMode() bool {
+	return mode == modeNetwork || mode == modeWatch
+}
+
+// 届いた手を大きめに出して、盤に落ちるように縮める
+type dropIn struct {
+	sz    size.Event
+	x, y  int
+	start clock.Time
+}
+
+// 縮みきるまでのフレーム数
+const dropFrames = 20
+
+func (d *dropIn) Arrange(e sprite.Engine, n *sprite.Node, t clock.Time) {
+	if d.start < 0 {
+		d.start = t
+	}
+	a := stoneAffine(d.sz, d.x, d.y)
+	k := float32(1)
+	if f := t - d.start; f < dropFrames {
+		k = 2 - float32(f)/dropFrames
+	}
+	// 石の中心を変えずに大きさだけ変える
+	w, h := a[0][0], a[1][1]
+	a[0][2] -= w * (k - 1) / 2
+	a[1][2] -= h * (k - 1) / 2
+	a[0][0], a[1][1] = w*k, h*k
+	e.SetTransform(n, a)
+}
@@ -0,0 +1,61 @@
+package puzzle
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// 解いた問題の記録
+type Progress struct {
+	Solved map[string]time.Time `json:"solved"`
+}
+
+// ファイルがなければ空の記録を返す
+func LoadProgress(path string) (*Progress, error) {
+	pr := &Progress{Solved: map[string]time.Time{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return pr, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, pr); err != nil {
+		return nil, err
+	}
+	if pr.Solved == nil {
+		pr.Solved = map[string]time.Time{}
+	}
+	return pr, nil
+}
+
+func (pr *Progress) Save(path string) error {
+	data, err := json.MarshalIndent(pr, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func (pr *Progress) MarkSolved(name string) {
+	if _, ok := pr.Solved[name]; !ok {
+		pr.Solved[name] = time.Now()
+	}
+}
+
+func (pr *Progress) IsSolved(name string) bool {
+	_, ok := pr.Solved[name]
+	return ok
+}
+
+// まだ解いていない最初の問題(全部解いていれば最初の問題)
+func (pr *Progress) Next(puzzles []*Puzzle) int {
+	for i, p := range puzzles {
+		if !pr.IsSolved(p.Name) {
+			return i
+		}
+	}
+	return 0
+}
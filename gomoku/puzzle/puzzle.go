@@ -0,0 +1,223 @@
+package puzzle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"../ai"
+	"../board"
+)
+
+// 検証で読む深さ
+const (
+	vctDepth = 3
+	vcfDepth = 12
+)
+
+type Puzzle struct {
+	Name     string
+	Board    *board.Board
+	ToMove   int
+	Solution []board.Point // 攻め方と受け方の手を交互に並べた正解手順
+}
+
+// 問題ファイルを読む
+//
+//	name: 四追い 1
+//	size: 13
+//	tomove: black
+//	solution: g6 g5 g10
+//	board:
+//	.............
+//	......x......
+//	......o......
+//	...
+//
+// 盤面は.が空点、xが黒、oが白で、size行並べる
+// 問題の間は空行で区切る
+// board:の代わりに一行の局面表記(board.ParsePosition)を position: で書いてもよい
+// そのときは手番も局面表記で決まるので、tomove: とは一緒に書けない
+func Load(r io.Reader) ([]*Puzzle, error) {
+	var (
+		puzzles []*Puzzle
+		p       *Puzzle
+		size    = 13
+		sol     string
+		rows    []string
+		inBoard bool
+		hasSide bool // tomove: があった
+		lineNo  int
+	)
+	finish := func() error {
+		if p == nil {
+			return nil
+		}
+		if p.Board != nil && rows == nil {
+			// position: で盤面が決まっている
+			size = board.Size(p.Board)
+		} else if len(rows) != size {
+			return fmt.Errorf("puzzle: %q: board has %d rows, want %d", p.Name, len(rows), size)
+		}
+		if rows != nil {
+			p.Board = board.New(size)
+		}
+		for y, row := range rows {
+			if len(row) != size {
+				return fmt.Errorf("puzzle: %q: row %d has %d points, want %d", p.Name, y+1, len(row), size)
+			}
+			for x, c := range row {
+				switch c {
+				case 'x':
+					board.Set(p.Board, x, y, board.Black)
+				case 'o':
+					board.Set(p.Board, x, y, board.White)
+				case '.':
+				default:
+					return fmt.Errorf("puzzle: %q: bad point %q", p.Name, c)
+				}
+			}
+		}
+		for _, f := range strings.Fields(sol) {
+			pt, err := board.ParsePoint(f)
+			if err != nil {
+				return fmt.Errorf("puzzle: %q: %v", p.Name, err)
+			}
+			p.Solution = append(p.Solution, pt)
+		}
+		puzzles = append(puzzles, p)
+		p, size, sol, rows, inBoard, hasSide = nil, 13, "", nil, false, false
+		return nil
+	}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		lineNo++
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "" {
+			if inBoard || p != nil && p.Board != nil {
+				if err := finish(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if p == nil {
+			p = &Puzzle{ToMove: board.Black}
+		}
+		if inBoard {
+			rows = append(rows, line)
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("puzzle: line %d: missing ':'", lineNo)
+		}
+		key, val := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch key {
+		case "name":
+			p.Name = val
+		case "size":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 5 || n > 26 {
+				return nil, fmt.Errorf("puzzle: line %d: bad size %q", lineNo, val)
+			}
+			size = n
+		case "tomove":
+			if p.Board != nil {
+				return nil, fmt.Errorf("puzzle: line %d: tomove conflicts with position", lineNo)
+			}
+			hasSide = true
+			switch val {
+			case "black":
+				p.ToMove = board.Black
+			case "white":
+				p.ToMove = board.White
+			default:
+				return nil, fmt.Errorf("puzzle: line %d: bad side %q", lineNo, val)
+			}
+		case "solution":
+			sol = val
+		case "board":
+			if p.Board != nil {
+				return nil, fmt.Errorf("puzzle: line %d: board conflicts with position", lineNo)
+			}
+			inBoard = true
+		case "position":
+			// 手番は局面表記の方で決まるので、tomove: と一緒には書けない
+			if hasSide {
+				return nil, fmt.Errorf("puzzle: line %d: position conflicts with tomove", lineNo)
+			}
+			if p.Board != nil {
+				return nil, fmt.Errorf("puzzle: line %d: position given twice", lineNo)
+			}
+			b, toMove, _, err := board.ParsePosition(val)
+			if err != nil {
+				return nil, fmt.Errorf("puzzle: line %d: %v", lineNo, err)
+			}
+			p.Board, p.ToMove = b, toMove
+		default:
+			return nil, fmt.Errorf("puzzle: line %d: unknown key %q", lineNo, key)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return puzzles, nil
+}
+
+// 正解手順が盤上で打てて五で終わること、ソルバーでも勝ちが見つかることを確かめる
+func Verify(p *Puzzle) error {
+	if len(p.Solution) == 0 {
+		return fmt.Errorf("puzzle: %q: no solution", p.Name)
+	}
+	if _, ok := ai.VCT(p.Board, p.ToMove, vctDepth); !ok {
+		if _, ok := ai.VCF(p.Board, p.ToMove, vcfDepth); !ok {
+			return fmt.Errorf("puzzle: %q: solver found no forced win", p.Name)
+		}
+	}
+	b := board.Copy(p.Board)
+	which := p.ToMove
+	for i, pt := range p.Solution {
+		if board.At(b, pt.X, pt.Y) != board.Space {
+			return fmt.Errorf("puzzle: %q: solution move %d at %s is not playable", p.Name, i+1, board.FormatPoint(pt))
+		}
+		board.Set(b, pt.X, pt.Y, which)
+		if board.FiveAt(b, pt.X, pt.Y) {
+			if which != p.ToMove {
+				return fmt.Errorf("puzzle: %q: defender wins at move %d", p.Name, i+1)
+			}
+			if i != len(p.Solution)-1 {
+				return fmt.Errorf("puzzle: %q: solution continues after five", p.Name)
+			}
+			return nil
+		}
+		which = board.Opponent(which)
+	}
+	// 最後が活四などで終わる手順は、その後ソルバーで勝ちを確かめる
+	if which == p.ToMove {
+		if _, ok := ai.VCF(b, which, vcfDepth); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("puzzle: %q: solution does not end in a win", p.Name)
+}
+
+// 受け方が受けた後も攻め方に勝ちが残っているか
+// 受け方が打った後の、攻め方の手番で呼ぶ
+// 攻め方と受け方が一手ずつ進めたので、VCTは一段浅く読む
+func StillWinning(b *board.Board, attacker int) bool {
+	if _, ok := ai.VCF(b, attacker, vcfDepth); ok {
+		return true
+	}
+	_, ok := ai.VCT(b, attacker, vctDepth-1)
+	return ok
+}
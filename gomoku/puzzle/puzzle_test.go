@@ -0,0 +1,32 @@
+package puzzle
+
+import (
+	"strings"
+	"testing"
+
+	"../board"
+)
+
+func TestLoadPosition(t *testing.T) {
+	ps, err := Load(strings.NewReader("name: p\nposition: 5/5/2x2/5/5 w\nsolution: a1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 1 || ps[0].ToMove != board.White || board.At(ps[0].Board, 2, 2) != board.Black {
+		t.Fatalf("puzzles = %+v", ps)
+	}
+}
+
+// 手番や盤面を二通りに書いたら、どちらかを黙って使わずに断る
+func TestLoadConflicts(t *testing.T) {
+	for _, in := range []string{
+		"tomove: black\nposition: 5/5/2x2/5/5 w\n",
+		"position: 5/5/2x2/5/5 w\ntomove: black\n",
+		"position: 5/5/2x2/5/5 w\nposition: 5/5/5/5/5 b\n",
+		"size: 5\nposition: 5/5/2x2/5/5 w\nboard:\n.....\n.....\n.....\n.....\n.....\n",
+	} {
+		if _, err := Load(strings.NewReader(in)); err == nil {
+			t.Errorf("Load accepted %q", in)
+		}
+	}
+}
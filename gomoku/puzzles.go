@@ -0,0 +1,98 @@
+package main
+
+// 組み込みの詰め五目
+const builtinPuzzles = `name: 活四
+size: 13
+tomove: black
+solution: f7 e7 j7
+board:
+.............
+.............
+.............
+.............
+.............
+.....o.......
+......xxx....
+.......o.....
+.............
+.............
+.............
+.............
+.............
+
+name: 四々
+size: 13
+tomove: black
+solution: h7 i7 h8
+board:
+.............
+.............
+.......o.....
+.......x.....
+.....o.x.....
+.......x.....
+...oxxx......
+......o......
+.............
+.............
+.............
+.............
+.............
+
+name: 四三
+size: 13
+tomove: black
+solution: h7 i7 h8 h4 h9
+board:
+.............
+.............
+.............
+.............
+.......xo....
+.....o.x.....
+...oxxx......
+.............
+....o........
+.............
+.............
+.............
+.............
+
+name: 三々
+size: 13
+tomove: black
+solution: g7 d7 g8 g4 g9
+board:
+.............
+.............
+.............
+.........o...
+......x......
+....o.x......
+....xx.......
+.......o.....
+.............
+.............
+.............
+.............
+.............
+
+name: 白番の四々
+size: 13
+tomove: white
+solution: i7 j7 i8
+board:
+.............
+.............
+........x....
+........o....
+.....x..o....
+........o....
+....xooo.....
+.............
+.........x...
+...x.........
+.............
+.............
+.............
+`
@@ -0,0 +1,19 @@
+package rating
+
+import "math"
+
+const (
+	InitialElo = 1500
+	EloK       = 32
+)
+
+// aがbに勝つ見込み
+func Expected(a float64, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// scoreはaから見た得点(勝ち1、引き分け0.5、負け0)
+func UpdateElo(a float64, b float64, score float64) (float64, float64) {
+	d := EloK * (score - Expected(a, b))
+	return a + d, b - d
+}
@@ -0,0 +1,91 @@
+package rating
+
+import "math"
+
+// Glicko-2のレーティング(表示用の尺度で持つ)
+type Glicko struct {
+	Rating     float64 `json:"rating"`
+	RD         float64 `json:"rd"`
+	Volatility float64 `json:"volatility"`
+}
+
+var DefaultGlicko = Glicko{Rating: 1500, RD: 350, Volatility: 0.06}
+
+// 一局の相手と得点
+type GlickoResult struct {
+	Opponent Glicko
+	Score    float64
+}
+
+const (
+	glickoScale = 173.7178
+	glickoTau   = 0.5
+	glickoEps   = 0.000001
+)
+
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// 一つの期間の結果でレーティングを更新する
+// 対局がなければRDだけが広がる
+func UpdateGlicko(p Glicko, results []GlickoResult) Glicko {
+	mu := (p.Rating - 1500) / glickoScale
+	phi := p.RD / glickoScale
+	sigma := p.Volatility
+	if len(results) == 0 {
+		phi = math.Sqrt(phi*phi + sigma*sigma)
+		return Glicko{Rating: p.Rating, RD: math.Min(phi*glickoScale, 350), Volatility: sigma}
+	}
+
+	var vInv, sum float64
+	for _, r := range results {
+		muj := (r.Opponent.Rating - 1500) / glickoScale
+		g := glickoG(r.Opponent.RD / glickoScale)
+		e := 1 / (1 + math.Exp(-g*(mu-muj)))
+		vInv += g * g * e * (1 - e)
+		sum += g * (r.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	// 変動率はIllinois法で求める
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEps {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	sigma = math.Exp(A / 2)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * sum
+	return Glicko{
+		Rating:     mu*glickoScale + 1500,
+		RD:         phi * glickoScale,
+		Volatility: sigma,
+	}
+}
@@ -0,0 +1,221 @@
+package rating
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"../archive"
+	"../board"
+	"../record"
+)
+
+// コンピュータの対局者名はAIName(探索の深さ)で付ける
+const aiPrefix = "AI:"
+
+func AIName(level int) string {
+	return aiPrefix + strconv.Itoa(level)
+}
+
+func AILevel(name string) (int, bool) {
+	if !strings.HasPrefix(name, aiPrefix) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(name[len(aiPrefix):])
+	return n, err == nil
+}
+
+// 勝ち・負け・引き分けの数
+type Score struct {
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Draws  int `json:"draws"`
+}
+
+func (s Score) Games() int {
+	return s.Wins + s.Losses + s.Draws
+}
+
+// 引き分けを半分の勝ちとして数えた勝率
+func (s Score) Rate() float64 {
+	if s.Games() == 0 {
+		return 0
+	}
+	return (float64(s.Wins) + float64(s.Draws)/2) / float64(s.Games())
+}
+
+func (s *Score) add(score float64) {
+	switch score {
+	case 1:
+		s.Wins++
+	case 0:
+		s.Losses++
+	default:
+		s.Draws++
+	}
+}
+
+type Player struct {
+	Name       string         `json:"name"`
+	Elo        float64        `json:"elo"`
+	Glicko     Glicko         `json:"glicko"`
+	Black      Score          `json:"black"`
+	White      Score          `json:"white"`
+	TotalMoves int            `json:"totalMoves"`
+	VsAI       map[int]*Score `json:"vsAI,omitempty"` // AIの強さごとの成績
+}
+
+func (p *Player) Games() int {
+	return p.Black.Games() + p.White.Games()
+}
+
+// 手番の色ごとの勝率
+func (p *Player) WinRate(which int) float64 {
+	if which == board.White {
+		return p.White.Rate()
+	}
+	return p.Black.Rate()
+}
+
+func (p *Player) AverageLength() float64 {
+	if p.Games() == 0 {
+		return 0
+	}
+	return float64(p.TotalMoves) / float64(p.Games())
+}
+
+// 対局者とレーティングの一覧
+// Appliedにはレーティングに反映済みのアーカイブの棋譜を持つ
+type Registry struct {
+	Players map[string]*Player `json:"players"`
+	Applied map[string]bool    `json:"applied"`
+}
+
+func NewRegistry() *Registry {
+	return &Registry{Players: map[string]*Player{}, Applied: map[string]bool{}}
+}
+
+// ファイルがなければ空の一覧を返す
+func LoadRegistry(path string) (*Registry, error) {
+	r := NewRegistry()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	if r.Players == nil {
+		r.Players = map[string]*Player{}
+	}
+	if r.Applied == nil {
+		r.Applied = map[string]bool{}
+	}
+	return r, nil
+}
+
+func (r *Registry) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// 対局者を取り出す(いなければ登録する)
+func (r *Registry) Player(name string) *Player {
+	p, ok := r.Players[name]
+	if !ok {
+		p = &Player{Name: name, Elo: InitialElo, Glicko: DefaultGlicko}
+		r.Players[name] = p
+	}
+	return p
+}
+
+// レーティングの高い順
+func (r *Registry) Ranking() []*Player {
+	var ps []*Player
+	for _, p := range r.Players {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].Elo != ps[j].Elo {
+			return ps[i].Elo > ps[j].Elo
+		}
+		return ps[i].Name < ps[j].Name
+	})
+	return ps
+}
+
+// 一局の結果を反映する
+func (r *Registry) Record(black string, white string, result string, moves int) error {
+	var score float64
+	switch {
+	case strings.HasPrefix(result, record.BlackWin):
+		score = 1
+	case strings.HasPrefix(result, record.WhiteWin):
+		score = 0
+	case result == record.Draw:
+		score = 0.5
+	default:
+		return fmt.Errorf("rating: bad result %q", result)
+	}
+	if black == "" || white == "" || black == white {
+		return fmt.Errorf("rating: bad players %q and %q", black, white)
+	}
+
+	pb, pw := r.Player(black), r.Player(white)
+	pb.Elo, pw.Elo = UpdateElo(pb.Elo, pw.Elo, score)
+	// 一局ごとを一つの期間として扱う
+	gb := UpdateGlicko(pb.Glicko, []GlickoResult{{Opponent: pw.Glicko, Score: score}})
+	gw := UpdateGlicko(pw.Glicko, []GlickoResult{{Opponent: pb.Glicko, Score: 1 - score}})
+	pb.Glicko, pw.Glicko = gb, gw
+
+	pb.Black.add(score)
+	pw.White.add(1 - score)
+	pb.TotalMoves += moves
+	pw.TotalMoves += moves
+	if level, ok := AILevel(white); ok {
+		pb.vsAI(level).add(score)
+	}
+	if level, ok := AILevel(black); ok {
+		pw.vsAI(level).add(1 - score)
+	}
+	return nil
+}
+
+func (p *Player) vsAI(level int) *Score {
+	if p.VsAI == nil {
+		p.VsAI = map[int]*Score{}
+	}
+	s, ok := p.VsAI[level]
+	if !ok {
+		s = &Score{}
+		p.VsAI[level] = s
+	}
+	return s
+}
+
+// アーカイブのまだ反映していない棋譜を日付順に反映し、その数を返す
+// 対局者名か結果のない棋譜は数えない
+func (r *Registry) Update(a *archive.Archive) int {
+	n := 0
+	for _, e := range a.List(archive.Query{}) {
+		if r.Applied[e.ID] {
+			continue
+		}
+		if err := r.Record(e.Black, e.White, e.Result, e.Moves); err != nil {
+			continue
+		}
+		r.Applied[e.ID] = true
+		n++
+	}
+	return n
+}
@@ -0,0 +1,156 @@
+package record
+
+import (
+	"fmt"
+
+	"../board"
+)
+
+// 棋譜の木をたどるためのカーソル
+// Boardは常にNodeの局面になっている
+type Cursor struct {
+	Game  *Game
+	Node  *Node
+	Board *board.Board
+}
+
+func NewCursor(g *Game) (*Cursor, error) {
+	b, err := Position(g, g.Root)
+	if err != nil {
+		return nil, err
+	}
+	return &Cursor{Game: g, Node: g.Root, Board: b}, nil
+}
+
+// 次に打つ側
+func (c *Cursor) ToMove() int {
+	if c.Node.Move.Which != board.Space {
+		return board.Opponent(c.Node.Move.Which)
+	}
+	if c.Node.Parent != nil {
+		// 着手のない節は親の手番を引き継ぐ
+		return (&Cursor{Node: c.Node.Parent}).ToMove()
+	}
+	blacks, whites := 0, 0
+	for _, m := range c.Node.Setup {
+		if m.Which == board.Black {
+			blacks++
+		} else {
+			whites++
+		}
+	}
+	if blacks > whites {
+		return board.White
+	}
+	return board.Black
+}
+
+func (c *Cursor) Variations() []*Node {
+	return c.Node.Children
+}
+
+// 本譜(最初の変化)を一手進める
+func (c *Cursor) Next() bool {
+	return c.Branch(0)
+}
+
+// i番目の変化に進む
+func (c *Cursor) Branch(i int) bool {
+	if i < 0 || i >= len(c.Node.Children) {
+		return false
+	}
+	n := c.Node.Children[i]
+	for _, m := range n.Setup {
+		board.Set(c.Board, m.X, m.Y, m.Which)
+	}
+	if n.Move.Which != board.Space {
+		board.Set(c.Board, n.Move.X, n.Move.Y, n.Move.Which)
+	}
+	c.Node = n
+	return true
+}
+
+func (c *Cursor) Prev() bool {
+	n := c.Node
+	if n.Parent == nil {
+		return false
+	}
+	if n.Move.Which != board.Space {
+		board.Set(c.Board, n.Move.X, n.Move.Y, board.Space)
+	}
+	for _, m := range n.Setup {
+		board.Set(c.Board, m.X, m.Y, board.Space)
+	}
+	c.Node = n.Parent
+	return true
+}
+
+// 手を打って進む(同じ手の変化があればそこへ進む)
+func (c *Cursor) Play(x int, y int) (*Node, error) {
+	if board.At(c.Board, x, y) != board.Space {
+		return nil, fmt.Errorf("record: %s is not playable", board.FormatPoint(board.Point{X: x, Y: y}))
+	}
+	n := AddChild(c.Node, board.Move{X: x, Y: y, Which: c.ToMove()})
+	board.Set(c.Board, x, y, n.Move.Which)
+	c.Node = n
+	return n, nil
+}
+
+// 今の変化を兄弟の先頭(本譜側)に移す
+func (c *Cursor) Promote() {
+	p := c.Node.Parent
+	if p == nil {
+		return
+	}
+	for i, n := range p.Children {
+		if n == c.Node {
+			copy(p.Children[1:i+1], p.Children[:i])
+			p.Children[0] = n
+			return
+		}
+	}
+}
+
+// 根から今の節までを本譜にする
+func (c *Cursor) PromoteToMainLine() {
+	save := c.Node
+	for n := c.Node; n.Parent != nil; n = n.Parent {
+		c.Node = n
+		c.Promote()
+	}
+	c.Node = save
+}
+
+// 今の節を変化ごと消して親に戻る
+func (c *Cursor) Delete() bool {
+	n := c.Node
+	if !c.Prev() {
+		return false
+	}
+	p := c.Node
+	for i, ch := range p.Children {
+		if ch == n {
+			p.Children = append(p.Children[:i], p.Children[i+1:]...)
+			break
+		}
+	}
+	n.Parent = nil
+	return true
+}
+
+// 根から今の節まで進んだ手数
+func (c *Cursor) Depth() int {
+	d := 0
+	for n := c.Node; n.Parent != nil; n = n.Parent {
+		d++
+	}
+	return d
+}
+
+func (c *Cursor) AddMark(m Mark) {
+	c.Node.Marks = append(c.Node.Marks, m)
+}
+
+func (c *Cursor) SetComment(s string) {
+	c.Node.Comment = s
+}
@@ -0,0 +1,153 @@
+package record
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"../board"
+)
+
+// 拡張子で形式を決めて読む(.sgf, .lib, .psq, .pos)
+// .posは盤の大きさを持たないのでposSizeを使う
+func ReadFile(path string, posSize int) ([]*Game, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var g *Game
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".sgf":
+		return ReadSGFCollection(f)
+	case ".lib":
+		g, err = ReadRenLib(f)
+	case ".psq":
+		g, err = ReadPSQ(f)
+	case ".pos":
+		g, err = ReadPOS(f, posSize)
+	default:
+		return nil, fmt.Errorf("record: unknown file type %q", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []*Game{g}, nil
+}
+
+func WriteFile(path string, g *Game) error {
+	var write func(io.Writer, *Game) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".sgf":
+		write = WriteSGF
+	case ".lib":
+		write = WriteRenLib
+	case ".psq":
+		write = WritePSQ
+	case ".pos":
+		write = WritePOS
+	default:
+		return fmt.Errorf("record: unknown file type %q", path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, g); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 根から葉までの手順を全部返す(変化ごとに一局)
+func Lines(g *Game) [][]board.Move {
+	var lines [][]board.Move
+	var walk func(n *Node, moves []board.Move)
+	walk = func(n *Node, moves []board.Move) {
+		if n.Move.Which != board.Space {
+			moves = append(moves[:len(moves):len(moves)], n.Move)
+		}
+		if len(n.Children) == 0 {
+			lines = append(lines, moves)
+			return
+		}
+		for _, c := range n.Children {
+			walk(c, moves)
+		}
+	}
+	walk(g.Root, nil)
+	return lines
+}
+
+// 石が全部size路に収まるように平行移動した棋譜を返す
+func Fit(g *Game, size int) (*Game, error) {
+	if g.Size <= size {
+		out := *g
+		out.Size = size
+		return &out, nil
+	}
+	minX, minY, maxX, maxY := g.Size, g.Size, -1, -1
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		stones := append(n.Setup[:len(n.Setup):len(n.Setup)], n.Move)
+		for _, m := range stones {
+			if m.Which == board.Space {
+				continue
+			}
+			if m.X < minX {
+				minX = m.X
+			}
+			if m.Y < minY {
+				minY = m.Y
+			}
+			if m.X > maxX {
+				maxX = m.X
+			}
+			if m.Y > maxY {
+				maxY = m.Y
+			}
+		}
+		for _, c := range n.Children {
+			visit(c)
+		}
+	}
+	visit(g.Root)
+	if maxX-minX >= size || maxY-minY >= size {
+		return nil, fmt.Errorf("record: game does not fit on %dx%d", size, size)
+	}
+	// なるべく真ん中に寄せる
+	dx := (size-1-(maxX-minX))/2 - minX
+	dy := (size-1-(maxY-minY))/2 - minY
+	if maxX < 0 {
+		dx, dy = 0, 0
+	}
+
+	out := *g
+	out.Size = size
+	var copyNode func(n *Node, parent *Node) *Node
+	copyNode = func(n *Node, parent *Node) *Node {
+		c := &Node{Move: n.Move, Comment: n.Comment, Parent: parent}
+		if c.Move.Which != board.Space {
+			c.Move.X += dx
+			c.Move.Y += dy
+		}
+		for _, m := range n.Setup {
+			c.Setup = append(c.Setup, board.Move{X: m.X + dx, Y: m.Y + dy, Which: m.Which})
+		}
+		for _, m := range n.Marks {
+			if mx, my := m.X+dx, m.Y+dy; mx >= 0 && mx < size && my >= 0 && my < size {
+				c.Marks = append(c.Marks, Mark{X: mx, Y: my, Label: m.Label})
+			}
+		}
+		for _, ch := range n.Children {
+			c.Children = append(c.Children, copyNode(ch, c))
+		}
+		return c
+	}
+	out.Root = copyNode(g.Root, nil)
+	return &out, nil
+}
@@ -0,0 +1,186 @@
+package record
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"../board"
+)
+
+var (
+	psqHeader = regexp.MustCompile(`^Piskvorky (\d+)x(\d+),`)
+	psqMove   = regexp.MustCompile(`^(-?\d+),\s*(-?\d+)(,\s*-?\d+)?$`)
+)
+
+// Gomocupの.psq(Piskvorkの棋譜)を読む
+//
+//	Piskvorky 20x20, 11:11, 0
+//	10,10,0
+//	11,11,312
+//	...
+//	pbrain-black.exe
+//	pbrain-white.exe
+//	-1
+//
+// 座標は1から数え、黒から交互に打つ
+func ReadPSQ(r io.Reader) (*Game, error) {
+	s := bufio.NewScanner(r)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("record: empty psq")
+	}
+	m := psqHeader.FindStringSubmatch(strings.TrimSpace(s.Text()))
+	if m == nil {
+		return nil, fmt.Errorf("record: bad psq header %q", s.Text())
+	}
+	w, _ := strconv.Atoi(m[1])
+	h, _ := strconv.Atoi(m[2])
+	if w != h || w < 5 || w > 26 {
+		return nil, fmt.Errorf("record: unsupported psq board %dx%d", w, h)
+	}
+
+	g := New(w)
+	n := g.Root
+	which := board.Black
+	var players []string
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if m := psqMove.FindStringSubmatch(line); m != nil && players == nil {
+			x, _ := strconv.Atoi(m[1])
+			y, _ := strconv.Atoi(m[2])
+			if x < 1 || x > w || y < 1 || y > w {
+				return nil, fmt.Errorf("record: psq move %q is off the board", line)
+			}
+			n = AddChild(n, board.Move{X: x - 1, Y: y - 1, Which: which})
+			which = board.Opponent(which)
+			continue
+		}
+		if _, err := strconv.Atoi(line); err == nil {
+			// 最後の数字(開局番号など)は使わない
+			continue
+		}
+		players = append(players, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(players) > 0 {
+		g.Black = players[0]
+	}
+	if len(players) > 1 {
+		g.White = players[1]
+	}
+	if _, err := Position(g, n); err != nil {
+		return nil, err
+	}
+	g.Result = ResultOf(g)
+	return g, nil
+}
+
+// .psqと.posは黒から交互に打った手しか持てないので、
+// 置石や続けて同じ色が打った手のある本譜は書かずにエラーにする
+func gomocupMoves(g *Game, format string) ([]board.Move, error) {
+	var moves []board.Move
+	which := board.Black
+	for n := g.Root; n != nil; {
+		if len(n.Setup) > 0 {
+			return nil, fmt.Errorf("record: %s cannot hold setup stones", format)
+		}
+		if n.Move.Which != board.Space {
+			if n.Move.Which != which {
+				return nil, fmt.Errorf("record: %s needs moves alternating from black", format)
+			}
+			moves = append(moves, n.Move)
+			which = board.Opponent(which)
+		}
+		if len(n.Children) == 0 {
+			break
+		}
+		n = n.Children[0]
+	}
+	return moves, nil
+}
+
+// 本譜だけを書く(考慮時間は0)
+func WritePSQ(w io.Writer, g *Game) error {
+	moves, err := gomocupMoves(g, "psq")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "Piskvorky %dx%d, 11:11, 0\r\n", g.Size, g.Size)
+	for _, m := range moves {
+		fmt.Fprintf(bw, "%d,%d,0\r\n", m.X+1, m.Y+1)
+	}
+	if g.Black != "" || g.White != "" {
+		fmt.Fprintf(bw, "%s\r\n%s\r\n", g.Black, g.White)
+	}
+	fmt.Fprintf(bw, "-1\r\n")
+	return bw.Flush()
+}
+
+// Gomocupの.pos(局面)を読む
+// 一行目が石の数、続いて0から数えた"x,y"が黒から交互に並ぶ
+func ReadPOS(r io.Reader, size int) (*Game, error) {
+	s := bufio.NewScanner(r)
+	var lines []string
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("record: empty pos")
+	}
+	count, err := strconv.Atoi(lines[0])
+	if err != nil || count < 0 || count != len(lines)-1 {
+		return nil, fmt.Errorf("record: pos has %d moves, header says %q", len(lines)-1, lines[0])
+	}
+
+	g := New(size)
+	n := g.Root
+	which := board.Black
+	for _, line := range lines[1:] {
+		m := psqMove.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("record: bad pos move %q", line)
+		}
+		x, _ := strconv.Atoi(m[1])
+		y, _ := strconv.Atoi(m[2])
+		if x < 0 || x >= size || y < 0 || y >= size {
+			return nil, fmt.Errorf("record: pos move %q is off the board", line)
+		}
+		n = AddChild(n, board.Move{X: x, Y: y, Which: which})
+		which = board.Opponent(which)
+	}
+	if _, err := Position(g, n); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// 本譜の最後の局面を書く
+func WritePOS(w io.Writer, g *Game) error {
+	moves, err := gomocupMoves(g, "pos")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%d\r\n", len(moves))
+	for _, m := range moves {
+		fmt.Fprintf(bw, "%d,%d\r\n", m.X, m.Y)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,133 @@
+package record
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"../board"
+)
+
+func readFile(t *testing.T, name string, read func(f *os.File) (*Game, error)) *Game {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func lastPosition(t *testing.T, g *Game) (*board.Board, board.Move) {
+	t.Helper()
+	moves := MainLine(g)
+	b, err := Position(g, nodeAt(g, len(moves)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, moves[len(moves)-1]
+}
+
+func nodeAt(g *Game, n int) *Node {
+	node := g.Root
+	for i := 0; i < n; i++ {
+		node = node.Children[0]
+	}
+	return node
+}
+
+func TestReadPSQ(t *testing.T) {
+	g := readFile(t, "testdata/five.psq", func(f *os.File) (*Game, error) { return ReadPSQ(f) })
+	if g.Size != 15 || g.Black != "pbrain-black.exe" || g.White != "pbrain-white.exe" {
+		t.Fatalf("game = %+v", g)
+	}
+	moves := MainLine(g)
+	if len(moves) != 23 || moves[0] != (board.Move{X: 7, Y: 7, Which: board.Black}) {
+		t.Fatalf("moves = %v", moves)
+	}
+	b, last := lastPosition(t, g)
+	if last != (board.Move{X: 7, Y: 8, Which: board.Black}) || !board.FiveAt(b, last.X, last.Y) || !board.GameEnd(b) {
+		t.Fatal("last move should make five")
+	}
+	if g.Result != BlackWin {
+		t.Fatalf("result = %q", g.Result)
+	}
+	// 一手前はまだ終わっていない
+	prev, err := Position(g, nodeAt(g, len(moves)-1))
+	if err != nil || board.GameEnd(prev) {
+		t.Fatalf("game over before the last move: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePSQ(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	g2, err := ReadPSQ(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moves2 := MainLine(g2); len(moves2) != len(moves) || g2.Result != BlackWin || g2.Black != g.Black {
+		t.Fatalf("round trip = %+v", g2)
+	}
+}
+
+func TestReadPOS(t *testing.T) {
+	g := readFile(t, "testdata/open.pos", func(f *os.File) (*Game, error) { return ReadPOS(f, 15) })
+	b, last := lastPosition(t, g)
+	if last != (board.Move{X: 5, Y: 9, Which: board.White}) || board.FiveAt(b, 7, 7) || board.GameEnd(b) {
+		t.Fatal("position should still be open")
+	}
+	if board.At(b, 7, 7) != board.Black || board.At(b, 8, 8) != board.White {
+		t.Fatal("stones misplaced")
+	}
+
+	var buf bytes.Buffer
+	if err := WritePOS(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("testdata/open.pos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(data) {
+		t.Fatalf("WritePOS = %q, want %q", buf.String(), data)
+	}
+}
+
+// 置石や交互でない手は、.psqでも.posでも書けない
+func TestWriteGomocupRejects(t *testing.T) {
+	setup := New(15)
+	setup.Root.Setup = []board.Move{{X: 7, Y: 7, Which: board.Black}}
+	AddChild(setup.Root, board.Move{X: 8, Y: 8, Which: board.White})
+
+	twice := FromMoves(15, []board.Move{{X: 7, Y: 7, Which: board.Black}, {X: 8, Y: 8, Which: board.Black}})
+
+	for _, g := range []*Game{setup, twice} {
+		var buf bytes.Buffer
+		if err := WritePSQ(&buf, g); err == nil {
+			t.Error("WritePSQ accepted")
+		}
+		if err := WritePOS(&buf, g); err == nil {
+			t.Error("WritePOS accepted")
+		}
+	}
+}
+
+func TestReadGomocupErrors(t *testing.T) {
+	for _, in := range []string{"", "Gomoku 15x15\r\n", "Piskvorky 15x20, 11:11, 0\r\n", "Piskvorky 15x15, 11:11, 0\r\n16,1,0\r\n", "Piskvorky 15x15, 11:11, 0\r\n1,1,0\r\n1,1,0\r\n"} {
+		if _, err := ReadPSQ(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadPSQ(%q) accepted", in)
+		}
+	}
+	for _, in := range []string{"", "2\r\n1,1\r\n", "1\r\n15,0\r\n", "1\r\nx,y\r\n"} {
+		if _, err := ReadPOS(strings.NewReader(in), 15); err == nil {
+			t.Errorf("ReadPOS(%q) accepted", in)
+		}
+	}
+}
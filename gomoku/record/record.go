@@ -0,0 +1,150 @@
+package record
+
+import (
+	"fmt"
+
+	"../board"
+)
+
+// 棋譜の結果(SGFのREと同じ書き方)
+const (
+	BlackWin = "B+"
+	WhiteWin = "W+"
+	Draw     = "Draw"
+	Void     = "Void" // 打ち始めてすぐにやめたなど、勝ち負けのない対局
+)
+
+type Game struct {
+	Size    int
+	Rule    board.Rule
+	Black   string
+	White   string
+	Result  string
+	Date    string
+	Comment string
+	Root    *Node // 初期配置(置石)だけを持つ根
+}
+
+// 変化を持てる棋譜の一手
+// Move.WhichがSpaceなら着手のない局面(根やコメントだけの節)
+type Node struct {
+	Move     board.Move
+	Setup    []board.Move
+	Comment  string
+	Marks    []Mark
+	Parent   *Node
+	Children []*Node
+}
+
+// 盤上の印(Labelが空なら×印)
+type Mark struct {
+	X     int
+	Y     int
+	Label string
+}
+
+func New(size int) *Game {
+	return &Game{Size: size, Root: &Node{}}
+}
+
+// 黒から交互に打った手順から一本道の棋譜を作る
+func FromMoves(size int, moves []board.Move) *Game {
+	g := New(size)
+	n := g.Root
+	for _, m := range moves {
+		n = AddChild(n, m)
+	}
+	g.Result = ResultOf(g)
+	return g
+}
+
+// 同じ手の子があればそれを返す
+func AddChild(n *Node, m board.Move) *Node {
+	for _, c := range n.Children {
+		if c.Move == m {
+			return c
+		}
+	}
+	c := &Node{Move: m, Parent: n}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// nの代わりの手順を変化として足す(nと同じ側から交互に打つ)
+func AddVariation(n *Node, line []board.Point, comment string) *Node {
+	if n.Parent == nil || len(line) == 0 {
+		return nil
+	}
+	which := n.Move.Which
+	v := n.Parent
+	var first *Node
+	for _, p := range line {
+		v = AddChild(v, board.Move{X: p.X, Y: p.Y, Which: which})
+		which = board.Opponent(which)
+		if first == nil {
+			first = v
+		}
+	}
+	if first != n && first.Comment == "" {
+		first.Comment = comment
+	}
+	return first
+}
+
+// 本譜(最初の変化をたどった手順)
+func MainLine(g *Game) []board.Move {
+	var moves []board.Move
+	for n := g.Root; len(n.Children) > 0; {
+		n = n.Children[0]
+		if n.Move.Which != board.Space {
+			moves = append(moves, n.Move)
+		}
+	}
+	return moves
+}
+
+// 根からnまでの手順
+func Path(n *Node) []*Node {
+	var path []*Node
+	for ; n != nil; n = n.Parent {
+		path = append([]*Node{n}, path...)
+	}
+	return path
+}
+
+// nの局面を盤に並べる
+func Position(g *Game, n *Node) (*board.Board, error) {
+	b := board.New(g.Size)
+	for i, p := range Path(n) {
+		stones := p.Setup
+		if p.Move.Which != board.Space {
+			stones = append(stones[:len(stones):len(stones)], p.Move)
+		}
+		for _, m := range stones {
+			if board.At(b, m.X, m.Y) != board.Space {
+				return nil, fmt.Errorf("record: move %d at %s is not playable", i, board.FormatPoint(board.Point{X: m.X, Y: m.Y}))
+			}
+			board.Set(b, m.X, m.Y, m.Which)
+		}
+	}
+	return b, nil
+}
+
+// 本譜の最後の手で五ができていれば勝敗を返す
+func ResultOf(g *Game) string {
+	n := g.Root
+	for len(n.Children) > 0 {
+		n = n.Children[0]
+	}
+	if n.Move.Which == board.Space {
+		return ""
+	}
+	b, err := Position(g, n)
+	if err != nil || !board.FiveAt(b, n.Move.X, n.Move.Y) {
+		return ""
+	}
+	if n.Move.Which == board.Black {
+		return BlackWin
+	}
+	return WhiteWin
+}
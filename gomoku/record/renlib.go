@@ -0,0 +1,204 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"../board"
+)
+
+// RenLibの盤は15路で固定
+const RenLibSize = 15
+
+var renlibMagic = []byte("\xffRenLib\xff")
+
+// 一手は2バイト(位置と次のフラグ)で、木を先行順に並べる
+const (
+	rlDown       = 0x80 // この節で枝が終わる
+	rlRight      = 0x40 // 右に兄弟(変化)がある
+	rlOldComment = 0x20
+	rlMark       = 0x10
+	rlComment    = 0x08
+	rlStart      = 0x04
+	rlNoMove     = 0x02
+	rlExtension  = 0x01
+)
+
+var errRenLibHeader = errors.New("record: not a RenLib file")
+
+// 位置は0なら着手なし、それ以外は y*16 + x + 1
+func renlibPos(x int, y int) byte {
+	return byte(y*16 + x + 1)
+}
+
+func ReadRenLib(r io.Reader) (*Game, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 20 || !bytes.Equal(data[:8], renlibMagic) {
+		return nil, errRenLibHeader
+	}
+	data = data[20:]
+
+	g := New(RenLibSize)
+	cur := g.Root
+	var stack []*Node
+	moveless := map[*Node]bool{}
+	first := true
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("record: truncated RenLib record")
+		}
+		pos, flags := data[0], data[1]
+		data = data[2:]
+		if flags&rlExtension != 0 {
+			return nil, fmt.Errorf("record: RenLib extension records are not supported")
+		}
+
+		var n *Node
+		if first && pos == 0 {
+			// 先頭の着手なしの節は根
+			n = g.Root
+		} else {
+			n = &Node{Parent: cur}
+			if pos != 0 {
+				x, y := int(pos-1)%16, int(pos-1)/16
+				if x >= RenLibSize || y >= RenLibSize {
+					return nil, fmt.Errorf("record: RenLib move %#x is off the board", pos)
+				}
+				n.Move = board.Move{X: x, Y: y}
+			} else {
+				moveless[n] = true
+			}
+			cur.Children = append(cur.Children, n)
+		}
+		first = false
+
+		if flags&rlMark != 0 && pos != 0 {
+			n.Marks = append(n.Marks, Mark{X: n.Move.X, Y: n.Move.Y})
+		}
+		if flags&(rlComment|rlOldComment) != 0 {
+			var c string
+			c, data, err = renlibString(data)
+			if err != nil {
+				return nil, err
+			}
+			n.Comment = c
+		}
+
+		if flags&rlRight != 0 && n != g.Root {
+			stack = append(stack, n)
+		}
+		if flags&rlDown != 0 {
+			if len(stack) == 0 {
+				break
+			}
+			s := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			cur = s.Parent
+		} else {
+			cur = n
+		}
+	}
+
+	// 色は深さで決まる(黒から交互)
+	var color func(n *Node, which int)
+	color = func(n *Node, which int) {
+		for _, c := range n.Children {
+			if moveless[c] {
+				color(c, which)
+				continue
+			}
+			c.Move.Which = which
+			color(c, board.Opponent(which))
+		}
+	}
+	color(g.Root, board.Black)
+	g.Result = ResultOf(g)
+	return g, nil
+}
+
+// 0で終わる文字列で、2バイト単位になるように0が足してある
+func renlibString(data []byte) (string, []byte, error) {
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return "", nil, fmt.Errorf("record: unterminated RenLib comment")
+	}
+	s := string(data[:i])
+	n := i + 1
+	if n%2 == 1 && n < len(data) {
+		n++
+	}
+	return s, data[n:], nil
+}
+
+func WriteRenLib(w io.Writer, g *Game) error {
+	if g.Size > RenLibSize {
+		return fmt.Errorf("record: RenLib cannot hold a %dx%d board", g.Size, g.Size)
+	}
+	if len(g.Root.Setup) > 0 {
+		return fmt.Errorf("record: RenLib cannot hold setup stones")
+	}
+	bw := bufio.NewWriter(w)
+	bw.Write(renlibMagic)
+	bw.Write([]byte{3, 0})
+	bw.Write(bytes.Repeat([]byte{0xff}, 10))
+
+	flags := byte(rlNoMove)
+	if len(g.Root.Children) == 0 {
+		flags |= rlDown
+	}
+	writeRenLibNode(bw, g.Root, 0, flags)
+	for i, c := range g.Root.Children {
+		if err := writeRenLibTree(bw, c, i < len(g.Root.Children)-1, board.Black); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func writeRenLibTree(bw *bufio.Writer, n *Node, right bool, which int) error {
+	if n.Move.Which != which {
+		return fmt.Errorf("record: RenLib needs alternating moves starting with black")
+	}
+	var flags byte
+	if right {
+		flags |= rlRight
+	}
+	if len(n.Children) == 0 {
+		flags |= rlDown
+	}
+	for _, m := range n.Marks {
+		if m.X == n.Move.X && m.Y == n.Move.Y && m.Label == "" {
+			flags |= rlMark
+		}
+	}
+	writeRenLibNode(bw, n, renlibPos(n.Move.X, n.Move.Y), flags)
+	for i, c := range n.Children {
+		if err := writeRenLibTree(bw, c, i < len(n.Children)-1, board.Opponent(which)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRenLibNode(bw *bufio.Writer, n *Node, pos byte, flags byte) {
+	if n.Comment != "" {
+		flags |= rlComment
+	}
+	bw.Write([]byte{pos, flags})
+	if n.Comment != "" {
+		c := []byte(n.Comment)
+		c = bytes.Replace(c, []byte{0}, nil, -1)
+		c = append(c, 0)
+		if len(c)%2 == 1 {
+			c = append(c, 0)
+		}
+		bw.Write(c)
+	}
+}
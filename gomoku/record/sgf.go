@@ -0,0 +1,388 @@
+package record
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"../board"
+)
+
+// 五目並べのSGF(GM[4])を書く
+func WriteSGF(w io.Writer, g *Game) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("(;GM[4]FF[4]CA[UTF-8]")
+	fmt.Fprintf(bw, "SZ[%d]RU[%s]", g.Size, g.Rule)
+	writeProp(bw, "PB", g.Black)
+	writeProp(bw, "PW", g.White)
+	writeProp(bw, "RE", g.Result)
+	writeProp(bw, "DT", g.Date)
+	writeProp(bw, "GC", g.Comment)
+	writeNodeProps(bw, g.Root)
+	writeChildren(bw, g.Root)
+	bw.WriteString(")\n")
+	return bw.Flush()
+}
+
+func writeChildren(bw *bufio.Writer, n *Node) {
+	switch len(n.Children) {
+	case 0:
+	case 1:
+		writeNode(bw, n.Children[0])
+	default:
+		for _, c := range n.Children {
+			bw.WriteString("(")
+			writeNode(bw, c)
+			bw.WriteString(")")
+		}
+	}
+}
+
+func writeNode(bw *bufio.Writer, n *Node) {
+	bw.WriteString("\n;")
+	switch n.Move.Which {
+	case board.Black:
+		fmt.Fprintf(bw, "B[%s]", sgfPoint(n.Move.X, n.Move.Y))
+	case board.White:
+		fmt.Fprintf(bw, "W[%s]", sgfPoint(n.Move.X, n.Move.Y))
+	}
+	writeNodeProps(bw, n)
+	writeChildren(bw, n)
+}
+
+func writeNodeProps(bw *bufio.Writer, n *Node) {
+	for _, which := range []int{board.Black, board.White} {
+		var pts []string
+		for _, m := range n.Setup {
+			if m.Which == which {
+				pts = append(pts, sgfPoint(m.X, m.Y))
+			}
+		}
+		if len(pts) == 0 {
+			continue
+		}
+		if which == board.Black {
+			bw.WriteString("AB")
+		} else {
+			bw.WriteString("AW")
+		}
+		for _, p := range pts {
+			bw.WriteString("[" + p + "]")
+		}
+	}
+	var ma, lb []string
+	for _, m := range n.Marks {
+		if m.Label == "" {
+			ma = append(ma, sgfPoint(m.X, m.Y))
+		} else {
+			lb = append(lb, sgfPoint(m.X, m.Y)+":"+m.Label)
+		}
+	}
+	writeList(bw, "MA", ma)
+	writeList(bw, "LB", lb)
+	writeProp(bw, "C", n.Comment)
+}
+
+func writeList(bw *bufio.Writer, id string, vs []string) {
+	if len(vs) == 0 {
+		return
+	}
+	bw.WriteString(id)
+	for _, v := range vs {
+		bw.WriteString("[" + escape(v) + "]")
+	}
+}
+
+func writeProp(bw *bufio.Writer, id string, v string) {
+	if v == "" {
+		return
+	}
+	bw.WriteString(id + "[" + escape(v) + "]")
+}
+
+func escape(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	return strings.Replace(v, "]", `\]`, -1)
+}
+
+func sgfPoint(x int, y int) string {
+	return string([]byte{byte('a' + x), byte('a' + y)})
+}
+
+// SGFを読む(複数の棋譜があれば最初のもの)
+func ReadSGF(r io.Reader) (*Game, error) {
+	games, err := ReadSGFCollection(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(games) == 0 {
+		return nil, fmt.Errorf("record: no game in sgf")
+	}
+	return games[0], nil
+}
+
+func ReadSGFCollection(r io.Reader) ([]*Game, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &sgfParser{s: string(data)}
+	var games []*Game
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		g, err := p.game()
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	return games, nil
+}
+
+type sgfParser struct {
+	s   string
+	pos int
+	g   *Game
+}
+
+func (p *sgfParser) eof() bool { return p.pos >= len(p.s) }
+
+func (p *sgfParser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *sgfParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("record: sgf offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *sgfParser) expect(c byte) error {
+	p.skipSpace()
+	if p.eof() || p.s[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *sgfParser) game() (*Game, error) {
+	p.g = &Game{Size: 15}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	if err := p.expect(';'); err != nil {
+		return nil, err
+	}
+	props, err := p.props()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.gameInfo(props); err != nil {
+		return nil, err
+	}
+	p.g.Root = &Node{}
+	if err := p.nodeProps(p.g.Root, props); err != nil {
+		return nil, err
+	}
+	if err := p.sequence(p.g.Root); err != nil {
+		return nil, err
+	}
+	return p.g, nil
+}
+
+// ;で続く節と、(で始まる変化を読んで)まで進む
+func (p *sgfParser) sequence(parent *Node) error {
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return p.errorf("unexpected end")
+		}
+		switch p.s[p.pos] {
+		case ';':
+			p.pos++
+			props, err := p.props()
+			if err != nil {
+				return err
+			}
+			n := &Node{Parent: parent}
+			if err := p.nodeProps(n, props); err != nil {
+				return err
+			}
+			parent.Children = append(parent.Children, n)
+			parent = n
+		case '(':
+			for {
+				p.skipSpace()
+				if p.eof() || p.s[p.pos] != '(' {
+					break
+				}
+				p.pos++
+				if err := p.sequence(parent); err != nil {
+					return err
+				}
+			}
+		case ')':
+			p.pos++
+			return nil
+		default:
+			return p.errorf("unexpected %q", p.s[p.pos])
+		}
+	}
+}
+
+type sgfProp struct {
+	id     string
+	values []string
+}
+
+func (p *sgfParser) props() ([]sgfProp, error) {
+	var props []sgfProp
+	for {
+		p.skipSpace()
+		start := p.pos
+		for !p.eof() && p.s[p.pos] >= 'A' && p.s[p.pos] <= 'Z' {
+			p.pos++
+		}
+		if start == p.pos {
+			return props, nil
+		}
+		prop := sgfProp{id: p.s[start:p.pos]}
+		for {
+			p.skipSpace()
+			if p.eof() || p.s[p.pos] != '[' {
+				break
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			prop.values = append(prop.values, v)
+		}
+		if len(prop.values) == 0 {
+			return nil, p.errorf("property %s has no value", prop.id)
+		}
+		props = append(props, prop)
+	}
+}
+
+func (p *sgfParser) value() (string, error) {
+	p.pos++ // [
+	var v []byte
+	for !p.eof() {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '\\':
+			if !p.eof() {
+				v = append(v, p.s[p.pos])
+				p.pos++
+			}
+		case ']':
+			return string(v), nil
+		default:
+			v = append(v, c)
+		}
+	}
+	return "", p.errorf("unterminated value")
+}
+
+func (p *sgfParser) gameInfo(props []sgfProp) error {
+	for _, prop := range props {
+		v := prop.values[0]
+		switch prop.id {
+		case "GM":
+			if v != "4" {
+				return p.errorf("not a gomoku record (GM[%s])", v)
+			}
+		case "SZ":
+			size, err := strconv.Atoi(v)
+			if err != nil || size < 5 || size > 26 {
+				return p.errorf("bad size %q", v)
+			}
+			p.g.Size = size
+		case "RU":
+			// 知らないルールは自由ルールとして読む
+			p.g.Rule, _ = board.ParseRule(strings.ToLower(v))
+		case "PB":
+			p.g.Black = v
+		case "PW":
+			p.g.White = v
+		case "RE":
+			p.g.Result = v
+		case "DT":
+			p.g.Date = v
+		case "GC":
+			p.g.Comment = v
+		}
+	}
+	return nil
+}
+
+func (p *sgfParser) nodeProps(n *Node, props []sgfProp) error {
+	for _, prop := range props {
+		switch prop.id {
+		case "B", "W":
+			pt, err := p.point(prop.values[0])
+			if err != nil {
+				return err
+			}
+			n.Move = board.Move{X: pt.X, Y: pt.Y, Which: sgfColor(prop.id)}
+		case "AB", "AW":
+			for _, v := range prop.values {
+				pt, err := p.point(v)
+				if err != nil {
+					return err
+				}
+				n.Setup = append(n.Setup, board.Move{X: pt.X, Y: pt.Y, Which: sgfColor(prop.id[1:])})
+			}
+		case "MA":
+			for _, v := range prop.values {
+				pt, err := p.point(v)
+				if err != nil {
+					return err
+				}
+				n.Marks = append(n.Marks, Mark{X: pt.X, Y: pt.Y})
+			}
+		case "LB":
+			for _, v := range prop.values {
+				i := strings.IndexByte(v, ':')
+				if i < 0 {
+					return p.errorf("bad label %q", v)
+				}
+				pt, err := p.point(v[:i])
+				if err != nil {
+					return err
+				}
+				n.Marks = append(n.Marks, Mark{X: pt.X, Y: pt.Y, Label: v[i+1:]})
+			}
+		case "C":
+			n.Comment = prop.values[0]
+		}
+	}
+	return nil
+}
+
+func sgfColor(id string) int {
+	if id == "B" {
+		return board.Black
+	}
+	return board.White
+}
+
+func (p *sgfParser) point(v string) (board.Point, error) {
+	if len(v) != 2 {
+		return board.Point{}, p.errorf("bad point %q", v)
+	}
+	pt := board.Point{X: int(v[0] - 'a'), Y: int(v[1] - 'a')}
+	if pt.X < 0 || pt.X >= p.g.Size || pt.Y < 0 || pt.Y >= p.g.Size {
+		return board.Point{}, p.errorf("point %q is off the board", v)
+	}
+	return pt, nil
+}
@@ -0,0 +1,218 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"./archive"
+	"./rating"
+
+	"golang.org/x/mobile/event/size"
+	"golang.org/x/mobile/exp/f32"
+	"golang.org/x/mobile/exp/sprite"
+	"golang.org/x/mobile/gl"
+)
+
+// 成績画面に出す人数
+const statsPlayers = 8
+
+var registry *rating.Registry
+
+func ratingsPath() string {
+	return filepath.Join(dataDir(), "ratings.json")
+}
+
+// players.txtの一行目を黒、二行目を白の対局者名にする
+// ファイルがなければ名前なしで保存し、レーティングには数えない
+// rating.AIName の名前(AI:2など)にした側はエンジンが打つ
+func playerNames() (string, string) {
+	data, err := ioutil.ReadFile(filepath.Join(dataDir(), "players.txt"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return "", ""
+	}
+	lines := strings.Split(string(data), "\n")
+	if len(lines) < 2 {
+		return "", ""
+	}
+	return strings.TrimSpace(lines[0]), strings.TrimSpace(lines[1])
+}
+
+// アーカイブに増えた棋譜をレーティングに反映する
+func updateRatings(a *archive.Archive) {
+	if registry == nil {
+		r, err := rating.LoadRegistry(ratingsPath())
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		registry = r
+	}
+	if registry.Update(a) == 0 {
+		return
+	}
+	if err := registry.Save(ratingsPath()); err != nil {
+		log.Println(err)
+	}
+}
+
+// 上位の対局者ごとに、レーティング、黒番の勝率、白番の勝率を棒で出す
+// 左端に順位とレーティング、黒石と黒番の勝率、白石と白番の勝率を添える
+func showStats(glctx gl.Context, sz size.Event) {
+	onStart(glctx, sz)
+	mode = modeStats
+	endFlag = true
+	if a := openArchive(); a != nil {
+		updateRatings(a)
+	}
+	if registry == nil {
+		return
+	}
+
+	w := float32(sz.WidthPt)
+	top := float32((sz.HeightPt - sz.WidthPt) / 2)
+	rowH := w / statsPlayers
+	barH := rowH / 5
+	// 棒は見出しの右から
+	left := w / 4
+	barW := w - left
+	bar := func(y float32, p float64) {
+		if p < 0 {
+			p = 0
+		} else if p > 1 {
+			p = 1
+		}
+		n := newNode()
+		eng.SetSubTex(n, evalTexs[texEvalBlack])
+		eng.SetTransform(n, f32.Affine{
+			{barW * float32(p), 0, left},
+			{0, barH, y},
+		})
+		n = newNode()
+		eng.SetSubTex(n, evalTexs[texEvalWhite])
+		eng.SetTransform(n, f32.Affine{
+			{barW * float32(1-p), 0, left + barW*float32(p)},
+			{0, barH, y},
+		})
+	}
+	stone := func(y float32, tex int) {
+		n := newNode()
+		eng.SetSubTex(n, goisiTexs[tex])
+		eng.SetTransform(n, f32.Affine{
+			{barH, 0, 0},
+			{0, barH, y},
+		})
+	}
+	percent := func(p float64) string {
+		return strconv.Itoa(int(p*100+0.5)) + "%"
+	}
+	for i, p := range registry.Ranking() {
+		if i == statsPlayers {
+			break
+		}
+		y := top + rowH*float32(i) + barH/2
+		log.Printf("%d. %s Elo %.0f Glicko %.0f 黒 %.0f%% 白 %.0f%% 平均 %.1f手",
+			i+1, p.Name, p.Elo, p.Glicko.Rating,
+			p.WinRate(BLACK)*100, p.WinRate(WHITE)*100, p.AverageLength())
+		var levels []int
+		for level := range p.VsAI {
+			levels = append(levels, level)
+		}
+		sort.Ints(levels)
+		for _, level := range levels {
+			s := p.VsAI[level]
+			log.Printf("    対%s %d勝%d敗%d分", rating.AIName(level), s.Wins, s.Losses, s.Draws)
+		}
+		// レーティングは1200から1800までを棒の長さにする
+		drawDigits(0, y, barH, strconv.Itoa(i+1)+" "+strconv.Itoa(int(p.Elo+0.5)))
+		bar(y, (p.Elo-1200)/600)
+		stone(y+barH*1.5, texBlack)
+		drawDigits(barH*1.25, y+barH*1.5, barH, percent(p.WinRate(BLACK)))
+		bar(y+barH*1.5, p.WinRate(BLACK))
+		stone(y+barH*3, texWhite)
+		drawDigits(barH*1.25, y+barH*3, barH, percent(p.WinRate(WHITE)))
+		bar(y+barH*3, p.WinRate(WHITE))
+	}
+}
+
+// 見出しの数字は画像を使わずに3x5の点で描く
+var digitGlyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'%': {"#.#", "..#", ".#.", "#..", "#.#"},
+}
+
+// 一文字の画像の大きさ(点ひとつを4pxで描く)
+const glyphPx = 4
+
+var digitTexs map[rune]sprite.SubTex
+
+func loadDigitTextures() map[rune]sprite.SubTex {
+	var runes []rune
+	for r := range digitGlyphs {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	cw, ch := 3*glyphPx, 5*glyphPx
+	img := image.NewRGBA(image.Rect(0, 0, cw*len(runes), ch))
+	ink := color.RGBA{0x10, 0x10, 0x10, 0xff}
+	for i, r := range runes {
+		for y, row := range digitGlyphs[r] {
+			for x, c := range row {
+				if c != '#' {
+					continue
+				}
+				for dy := 0; dy < glyphPx; dy++ {
+					for dx := 0; dx < glyphPx; dx++ {
+						img.SetRGBA(cw*i+x*glyphPx+dx, y*glyphPx+dy, ink)
+					}
+				}
+			}
+		}
+	}
+	t, err := eng.LoadTexture(img)
+	if err != nil {
+		log.Fatal(err)
+	}
+	texs := map[rune]sprite.SubTex{}
+	for i, r := range runes {
+		texs[r] = sprite.SubTex{T: t, R: image.Rect(cw*i, 0, cw*(i+1), ch)}
+	}
+	return texs
+}
+
+// (x, y)から高さhで数字を並べる。描けない文字は空けておく
+func drawDigits(x float32, y float32, h float32, s string) {
+	cw := h * 3 / 5
+	for _, r := range s {
+		if tex, ok := digitTexs[r]; ok {
+			n := newNode()
+			eng.SetSubTex(n, tex)
+			eng.SetTransform(n, f32.Affine{
+				{cw, 0, x},
+				{0, h, y},
+			})
+		}
+		x += cw * 4 / 3
+	}
+}
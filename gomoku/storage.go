@@ -0,0 +1,20 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// 棋譜や記録を保存するディレクトリ
+func dataDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "gomoku")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Println(err)
+	}
+	return dir
+}
@@ -0,0 +1,256 @@
+package tournament
+
+import (
+	"../record"
+)
+
+// 総当たりのr回戦目(0から)
+// 一人目を固定して残りを回す(奇数なら空席を足して、当たった人は休み)
+func (t *Tournament) roundRobin(r int) []*Pairing {
+	ps := append([]string(nil), t.Players...)
+	if len(ps)%2 == 1 {
+		ps = append(ps, "")
+	}
+	n := len(ps)
+	rest := ps[1:]
+	k := r % len(rest)
+	rotated := append(append([]string{ps[0]}, rest[len(rest)-k:]...), rest[:len(rest)-k]...)
+	var round []*Pairing
+	for i := 0; i < n/2; i++ {
+		a, b := rotated[i], rotated[n-1-i]
+		if a == "" || b == "" {
+			// 総当たりの休みは点にしない
+			continue
+		}
+		round = append(round, t.colored(a, b))
+	}
+	return round
+}
+
+// スイス式
+// 点数の順に並べ、同点の組の上半分と下半分を、まだ当たっていない相手どうしで組む
+// 奇数なら、まだ不戦勝のない一番下の人を不戦勝にする
+func (t *Tournament) swiss() []*Pairing {
+	st := t.Standings()
+	order := make([]string, len(st))
+	points := map[string]float64{}
+	for i, s := range st {
+		order[i] = s.Name
+		points[s.Name] = s.Points
+	}
+	var round []*Pairing
+	if len(order)%2 == 1 {
+		bye := len(order) - 1
+		for i := len(order) - 1; i >= 0; i-- {
+			if !t.hadBye(order[i]) {
+				bye = i
+				break
+			}
+		}
+		round = append(round, &Pairing{Black: order[bye], Result: record.BlackWin})
+		order = append(order[:bye:bye], order[bye+1:]...)
+	}
+	pairs, ok := pairUp(order, points, t.played)
+	if !ok {
+		// 当たっていない相手だけでは組めなければ、再戦を許す
+		pairs, _ = pairUp(order, points, func(a, b string) bool { return false })
+	}
+	for _, p := range pairs {
+		round = append(round, t.colored(p[0], p[1]))
+	}
+	return round
+}
+
+// 上から順に、avoidでない相手を探して組む。組めなければfalse
+// 相手は同点の組の真ん中から探し、いなければ下の組から探す
+func pairUp(order []string, points map[string]float64, avoid func(a, b string) bool) ([][2]string, bool) {
+	if len(order) == 0 {
+		return nil, true
+	}
+	first := order[0]
+	var same, lower []int
+	for j := 1; j < len(order); j++ {
+		if points[order[j]] == points[first] {
+			same = append(same, j)
+		} else {
+			lower = append(lower, j)
+		}
+	}
+	if len(same) > 0 {
+		h := (len(same)+1)/2 - 1
+		same = append(same[h:len(same):len(same)], same[:h]...)
+	}
+	for _, j := range append(same, lower...) {
+		if avoid(first, order[j]) {
+			continue
+		}
+		rest := make([]string, 0, len(order)-2)
+		rest = append(rest, order[1:j]...)
+		rest = append(rest, order[j+1:]...)
+		if pairs, ok := pairUp(rest, points, avoid); ok {
+			return append([][2]string{{first, order[j]}}, pairs...), true
+		}
+	}
+	return nil, false
+}
+
+// 勝ち抜き
+// 一回戦はシードの上と下を当て、人数が2の累乗に足りない分は上のシードを不戦勝にする
+// 二回戦からは隣の山の勝者どうしを当てる
+func (t *Tournament) knockout() []*Pairing {
+	var round []*Pairing
+	if len(t.Rounds) == 0 {
+		n := len(t.Players)
+		slots := bracket(n)
+		for i := 0; i < len(slots); i += 2 {
+			a, b := slots[i], slots[i+1]
+			if b >= n {
+				round = append(round, &Pairing{Black: t.Players[a], Result: record.BlackWin})
+				continue
+			}
+			round = append(round, &Pairing{Black: t.Players[a], White: t.Players[b]})
+		}
+		return round
+	}
+	w := t.survivors()
+	for i := 0; i+1 < len(w); i += 2 {
+		// シードが上の側が黒
+		a, b := w[i], w[i+1]
+		if t.seed(b) < t.seed(a) {
+			a, b = b, a
+		}
+		round = append(round, &Pairing{Black: a, White: b})
+	}
+	return round
+}
+
+// 人数nを収める山のシード(0から)の並び
+// 上位のシードほど後で当たるように、1と2は決勝まで当たらない
+func bracket(n int) []int {
+	slots := []int{0}
+	for len(slots) < n {
+		size := len(slots) * 2
+		next := make([]int, 0, size)
+		for _, s := range slots {
+			next = append(next, s, size-1-s)
+		}
+		slots = next
+	}
+	return slots
+}
+
+// 前の回戦で勝ち残った人を山の順に返す
+func (t *Tournament) survivors() []string {
+	var w []string
+	seen := map[string]bool{}
+	for _, p := range t.Current() {
+		key := p.Black + "\x00" + p.White
+		if p.White < p.Black {
+			key = p.White + "\x00" + p.Black
+		}
+		if seen[key] {
+			continue
+		}
+		if win := t.matchWinner(p); win != "" {
+			seen[key] = true
+			w = append(w, win)
+		}
+	}
+	return w
+}
+
+// 今の回戦でpの二人の勝負がついていれば勝った方
+func (t *Tournament) matchWinner(p *Pairing) string {
+	if p.Bye() {
+		return p.Black
+	}
+	for _, q := range t.Current() {
+		if !q.has(p.Black) || !q.has(p.White) || !q.Played() || q.Result == record.Draw {
+			continue
+		}
+		if score(q, q.Black) == 1 {
+			return q.Black
+		}
+		return q.White
+	}
+	if t.games(p.Black, p.White) > MaxReplays {
+		if t.seed(p.White) < t.seed(p.Black) {
+			return p.White
+		}
+		return p.Black
+	}
+	return ""
+}
+
+func (t *Tournament) seed(name string) int {
+	for i, p := range t.Players {
+		if p == name {
+			return i
+		}
+	}
+	return len(t.Players)
+}
+
+func (t *Tournament) played(a string, b string) bool {
+	for _, round := range t.Rounds {
+		for _, p := range round {
+			if p.has(a) && p.has(b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *Tournament) hadBye(name string) bool {
+	for _, round := range t.Rounds {
+		for _, p := range round {
+			if p.Bye() && p.Black == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 色の偏りを直すように黒と白を決める
+// 黒の多い方が白を持ち、同じなら前の対局で白だった方が黒を持つ
+// それも同じなら、回戦ごとに上位の側の色を替える
+func (t *Tournament) colored(a string, b string) *Pairing {
+	da, la := t.colorHistory(a)
+	db, lb := t.colorHistory(b)
+	black := true
+	switch {
+	case da != db:
+		black = da < db
+	case la != lb:
+		black = la == "w"
+	default:
+		black = len(t.Rounds)%2 == 0
+	}
+	if black {
+		return &Pairing{Black: a, White: b}
+	}
+	return &Pairing{Black: b, White: a}
+}
+
+// nameの黒番の数から白番の数を引いたものと、最後に持った色("b", "w", "")
+func (t *Tournament) colorHistory(name string) (int, string) {
+	diff, last := 0, ""
+	for _, round := range t.Rounds {
+		for _, p := range round {
+			if p.Bye() {
+				continue
+			}
+			switch name {
+			case p.Black:
+				diff++
+				last = "b"
+			case p.White:
+				diff--
+				last = "w"
+			}
+		}
+	}
+	return diff, last
+}
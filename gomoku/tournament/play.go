@@ -0,0 +1,71 @@
+package tournament
+
+import (
+	"../ai"
+	"../board"
+	"../game"
+	"../rating"
+)
+
+// 局面から一手を決める
+type Mover func(g *game.Game) board.Point
+
+// nameがエンジン(rating.AINameの名前)ならその手の決め方を返す
+// レベルは読む深さで、0なら定跡を使うai.BestMove
+func EngineFor(name string) (Mover, bool) {
+	level, ok := rating.AILevel(name)
+	if !ok {
+		return nil, false
+	}
+	e := ai.Searcher{Depth: level}
+	return func(g *game.Game) board.Point {
+		return e.Move(g.Board, g.Turn, 0)
+	}, true
+}
+
+// 終局まで一局打つ。打てない手を返した側は負け
+func PlayGame(size int, rule board.Rule, black Mover, white Mover) *game.Game {
+	g := game.New(size)
+	g.Rule = rule
+	for !g.Over() {
+		m := black
+		if g.Turn == board.White {
+			m = white
+		}
+		p := m(g)
+		if err := g.Play(p.X, p.Y); err != nil {
+			g.Forfeit(g.Turn)
+		}
+	}
+	return g
+}
+
+// 今の回戦のうち、両方ともエンジンの対局を打って結果を入れ、打った対局を返す
+// 人の対局はRecordGameで入れる
+func (t *Tournament) PlayEngines(size int, rule board.Rule) ([]*game.Game, error) {
+	var games []*game.Game
+	// 勝ち抜きの打ち直しは回戦の後ろに足されるので、なくなるまで回す
+	for {
+		var p *Pairing
+		var black, white Mover
+		for _, q := range t.Current() {
+			if q.Played() {
+				continue
+			}
+			b, bok := EngineFor(q.Black)
+			w, wok := EngineFor(q.White)
+			if bok && wok {
+				p, black, white = q, b, w
+				break
+			}
+		}
+		if p == nil {
+			return games, nil
+		}
+		g := PlayGame(size, rule, black, white)
+		if err := t.RecordGame(g, p.Black, p.White); err != nil {
+			return games, err
+		}
+		games = append(games, g)
+	}
+}
@@ -0,0 +1,171 @@
+package tournament
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"../record"
+)
+
+// 順位表の一行
+type Standing struct {
+	Rank   int
+	Name   string
+	Points float64
+	// 当たった相手の点数の合計
+	Buchholz float64
+	// 勝った相手の点数と、引き分けた相手の点数の半分の合計
+	SonnebornBerger float64
+	Wins            int
+	Draws           int
+	Losses          int
+	Byes            int
+}
+
+// 点数、ブッフホルツ、ゾンネボルン・ベルガーの順で並べた順位表
+// すべて同じならシード順
+// 勝ち抜きでは、勝ち残った回戦の多い順に近くなる
+func (t *Tournament) Standings() []Standing {
+	points := map[string]float64{}
+	for _, round := range t.Rounds {
+		for _, p := range round {
+			if !p.Played() {
+				continue
+			}
+			points[p.Black] += score(p, p.Black)
+			if !p.Bye() {
+				points[p.White] += score(p, p.White)
+			}
+		}
+	}
+	st := make([]Standing, len(t.Players))
+	for i, name := range t.Players {
+		s := Standing{Name: name, Points: points[name]}
+		for _, round := range t.Rounds {
+			for _, p := range round {
+				if !p.Played() || !p.has(name) {
+					continue
+				}
+				if p.Bye() {
+					s.Byes++
+					continue
+				}
+				opp := p.opponent(name)
+				sc := score(p, name)
+				s.Buchholz += points[opp]
+				s.SonnebornBerger += sc * points[opp]
+				switch sc {
+				case 1:
+					s.Wins++
+				case 0.5:
+					s.Draws++
+				default:
+					s.Losses++
+				}
+			}
+		}
+		st[i] = s
+	}
+	sort.SliceStable(st, func(i, j int) bool {
+		a, b := st[i], st[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Buchholz != b.Buchholz {
+			return a.Buchholz > b.Buchholz
+		}
+		return a.SonnebornBerger > b.SonnebornBerger
+	})
+	for i := range st {
+		st[i].Rank = i + 1
+	}
+	return st
+}
+
+// 順位表の順に、回戦ごとの結果を並べたクロステーブルを書く
+//
+//	#  Player        R1    R2    R3     Pts   Buch     SB
+//	1  alice        +3b   =2w   +4b     2.5    4.0   3.25
+//
+// +は勝ち、=は引き分け、-は負けで、続く数字は相手の順位、bかwは自分の色
+// 不戦勝はbye、まだ打っていなければ.
+func (t *Tournament) WriteCrosstable(w io.Writer) error {
+	st := t.Standings()
+	rank := map[string]int{}
+	width := len("Player")
+	for _, s := range st {
+		rank[s.Name] = s.Rank
+		if len(s.Name) > width {
+			width = len(s.Name)
+		}
+	}
+	cells := make([][]string, len(st))
+	cellWidth := 4
+	for i, s := range st {
+		for _, round := range t.Rounds {
+			var games []string
+			for _, p := range round {
+				if p.has(s.Name) {
+					games = append(games, cell(p, s.Name, rank))
+				}
+			}
+			c := strings.Join(games, "")
+			if c == "" {
+				c = "-"
+			}
+			if len(c) > cellWidth {
+				cellWidth = len(c)
+			}
+			cells[i] = append(cells[i], c)
+		}
+	}
+
+	head := fmt.Sprintf("%3s  %-*s", "#", width, "Player")
+	for r := range t.Rounds {
+		head += fmt.Sprintf("  %*s", cellWidth, fmt.Sprintf("R%d", r+1))
+	}
+	head += fmt.Sprintf("  %5s  %5s  %6s\n", "Pts", "Buch", "SB")
+	if _, err := fmt.Fprintf(w, "%s (%s)\n%s", t.Name, t.Format, head); err != nil {
+		return err
+	}
+	for i, s := range st {
+		line := fmt.Sprintf("%3d  %-*s", s.Rank, width, s.Name)
+		for _, c := range cells[i] {
+			line += fmt.Sprintf("  %*s", cellWidth, c)
+		}
+		line += fmt.Sprintf("  %5.1f  %5.1f  %6.2f\n", s.Points, s.Buchholz, s.SonnebornBerger)
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cell(p *Pairing, name string, rank map[string]int) string {
+	if p.Bye() {
+		return "bye"
+	}
+	color := "b"
+	if p.White == name {
+		color = "w"
+	}
+	opp := rank[p.opponent(name)]
+	if !p.Played() {
+		return fmt.Sprintf(".%d%s", opp, color)
+	}
+	mark := "-"
+	switch sc := score(p, name); {
+	case p.Result == record.Draw:
+		mark = "="
+	case sc == 1:
+		mark = "+"
+	}
+	return fmt.Sprintf("%s%d%s", mark, opp, color)
+}
+
+// 打った対局の数(不戦勝は数えない)
+func (s Standing) Games() int {
+	return s.Wins + s.Draws + s.Losses
+}
@@ -0,0 +1,271 @@
+package tournament
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+
+	"../board"
+	"../game"
+	"../record"
+)
+
+var (
+	ErrRoundOpen   = errors.New("tournament: round has unplayed games")
+	ErrFinished    = errors.New("tournament: tournament is finished")
+	ErrNoPairing   = errors.New("tournament: no such game in this round")
+	ErrNoResult    = errors.New("tournament: game has no result")
+	ErrFewPlayers  = errors.New("tournament: need at least two players")
+	ErrDupPlayer   = errors.New("tournament: duplicate player")
+	ErrNotFinished = errors.New("tournament: game is not over")
+)
+
+// 勝ち抜きで引き分けたときに打ち直す回数
+// これだけ打ち直しても決まらなければシードが上の側が勝ち残る
+const MaxReplays = 2
+
+// 組み合わせ方
+type Format int
+
+const (
+	RoundRobin Format = iota // 総当たり
+	Swiss                    // スイス式
+	Knockout                 // 勝ち抜き(負けたら終わり)
+)
+
+var formatNames = map[Format]string{
+	RoundRobin: "roundrobin",
+	Swiss:      "swiss",
+	Knockout:   "knockout",
+}
+
+func (f Format) String() string {
+	if s, ok := formatNames[f]; ok {
+		return s
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+func ParseFormat(s string) (Format, error) {
+	for f, name := range formatNames {
+		if name == s {
+			return f, nil
+		}
+	}
+	return RoundRobin, fmt.Errorf("tournament: unknown format %q", s)
+}
+
+func (f Format) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *Format) UnmarshalText(text []byte) error {
+	v, err := ParseFormat(string(text))
+	*f = v
+	return err
+}
+
+// 一局分の組み合わせ
+// Whiteが空なら不戦勝(バイ)で、Resultは初めから入っている
+type Pairing struct {
+	Black  string `json:"black"`
+	White  string `json:"white,omitempty"`
+	Result string `json:"result,omitempty"` // record.BlackWinなど。打つまでは空
+}
+
+func (p *Pairing) Bye() bool {
+	return p.White == ""
+}
+
+func (p *Pairing) Played() bool {
+	return p.Result != ""
+}
+
+// 大会
+// 対局者は人でもエンジン(rating.AINameの名前)でもよい
+type Tournament struct {
+	Name    string       `json:"name"`
+	Format  Format       `json:"format"`
+	Players []string     `json:"players"` // シード順
+	Rounds  [][]*Pairing `json:"rounds"`
+	// スイス式の回戦数
+	NumRounds int `json:"num_rounds,omitempty"`
+}
+
+// playersはシード順に並べる
+// スイス式でroundsが0なら、回戦数は人数から決める
+func New(name string, f Format, players []string, rounds int) (*Tournament, error) {
+	if len(players) < 2 {
+		return nil, ErrFewPlayers
+	}
+	seen := map[string]bool{}
+	for _, p := range players {
+		if p == "" || seen[p] {
+			return nil, ErrDupPlayer
+		}
+		seen[p] = true
+	}
+	t := &Tournament{Name: name, Format: f, Players: append([]string(nil), players...)}
+	if f == Swiss {
+		if rounds <= 0 {
+			rounds = int(math.Ceil(math.Log2(float64(len(players)))))
+		}
+		t.NumRounds = rounds
+	}
+	return t, nil
+}
+
+func Load(path string) (*Tournament, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tournament{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Tournament) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// 今の回戦の組み合わせ(始まっていなければnil)
+func (t *Tournament) Current() []*Pairing {
+	if len(t.Rounds) == 0 {
+		return nil
+	}
+	return t.Rounds[len(t.Rounds)-1]
+}
+
+func roundDone(round []*Pairing) bool {
+	for _, p := range round {
+		if !p.Played() {
+			return false
+		}
+	}
+	return true
+}
+
+// 次の回戦を組む。今の回戦が終わっていなければErrRoundOpen
+func (t *Tournament) NextRound() ([]*Pairing, error) {
+	if !roundDone(t.Current()) {
+		return nil, ErrRoundOpen
+	}
+	if t.Finished() {
+		return nil, ErrFinished
+	}
+	var round []*Pairing
+	switch t.Format {
+	case RoundRobin:
+		round = t.roundRobin(len(t.Rounds))
+	case Swiss:
+		round = t.swiss()
+	case Knockout:
+		round = t.knockout()
+	}
+	t.Rounds = append(t.Rounds, round)
+	return round, nil
+}
+
+// すべての回戦を打ち終えたか
+func (t *Tournament) Finished() bool {
+	if !roundDone(t.Current()) {
+		return false
+	}
+	switch t.Format {
+	case RoundRobin:
+		n := len(t.Players)
+		if n%2 == 1 {
+			n++
+		}
+		return len(t.Rounds) >= n-1
+	case Swiss:
+		return len(t.Rounds) >= t.NumRounds
+	case Knockout:
+		return len(t.Rounds) > 0 && len(t.survivors()) <= 1
+	}
+	return true
+}
+
+// 今の回戦のblackとwhiteの対局の結果を入れる
+// 組み合わせと逆の色で打ったなら、組み合わせの色を入れ替える
+// 勝ち抜きで引き分けたら、MaxReplays回まで色を替えて打ち直す対局を足す
+func (t *Tournament) Record(black string, white string, result string) error {
+	if game.Winner(result) == board.Space && result != record.Draw {
+		return ErrNoResult
+	}
+	var p *Pairing
+	for _, q := range t.Current() {
+		if q.Played() {
+			continue
+		}
+		if q.Black == black && q.White == white {
+			p = q
+		} else if q.Black == white && q.White == black {
+			p = q
+			p.Black, p.White = black, white
+		}
+		if p != nil {
+			break
+		}
+	}
+	if p == nil {
+		return ErrNoPairing
+	}
+	p.Result = result
+	if t.Format == Knockout && result == record.Draw && t.games(black, white) <= MaxReplays {
+		round := len(t.Rounds) - 1
+		t.Rounds[round] = append(t.Rounds[round], &Pairing{Black: white, White: black})
+	}
+	return nil
+}
+
+// 今の回戦でaとbが打った対局の数
+func (t *Tournament) games(a string, b string) int {
+	n := 0
+	for _, p := range t.Current() {
+		if p.has(a) && p.has(b) && p.Played() {
+			n++
+		}
+	}
+	return n
+}
+
+// game.Gameで打ち終えた対局の結果を入れる
+func (t *Tournament) RecordGame(g *game.Game, black string, white string) error {
+	if !g.Over() {
+		return ErrNotFinished
+	}
+	return t.Record(black, white, g.Result)
+}
+
+// pの対局でnameが取った点数
+func score(p *Pairing, name string) float64 {
+	w := game.Winner(p.Result)
+	switch {
+	case p.Result == record.Draw:
+		return 0.5
+	case w == board.Black && p.Black == name, w == board.White && p.White == name:
+		return 1
+	}
+	return 0
+}
+
+func (p *Pairing) opponent(name string) string {
+	if p.Black == name {
+		return p.White
+	}
+	return p.Black
+}
+
+func (p *Pairing) has(name string) bool {
+	return p.Black == name || (p.White != "" && p.White == name)
+}